@@ -0,0 +1,121 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// analyzeLimiter bounds how often the analyze endpoint can spend OpenAI credits. It is created
+// lazily so ANALYZE_RATE_PER_MINUTE can come from the .env file.
+var analyzeLimiter = sync.OnceValue(func() *utils.RateLimiter {
+	perMinute, _ := strconv.Atoi(os.Getenv("ANALYZE_RATE_PER_MINUTE"))
+	if perMinute <= 0 {
+		perMinute = 10
+	}
+	return utils.NewRateLimiter(time.Minute/time.Duration(perMinute), perMinute)
+})
+
+// analyzeReadmeHandler runs the analyzer on README text supplied by the caller, without fetching
+// anything from GitHub or writing to the database.
+func analyzeReadmeHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Name   string `json:"name"`
+		Readme string `json:"readme"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(input.Readme) == "" {
+		http.Error(w, "readme is required", http.StatusBadRequest)
+		return
+	}
+
+	if !analyzeLimiter().Allow() {
+		http.Error(w, "Too many analysis requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	analysis, err := utils.AnalyzeWithOpenAI(r.Context(), openaiClient, input.Name, input.Readme, "")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error analyzing readme: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	analysis.Configs = utils.DedupeConfigs(utils.DropInstallCommands(analysis.Configs))
+	utils.MarkPreferred(analysis.Configs)
+	analysis.Category = utils.NormalizeCategories(analysis.Category)
+
+	writeJSON(w, r, analysis)
+}
+
+// analyzeRepoHandler runs the analyzer on a stored repository's README and returns the manifest
+// it would produce, for trying out prompt changes. Only dry runs are supported; nothing is written
+// to the database, use the generate endpoint to store a new analysis.
+func analyzeRepoHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Query().Get("dryRun") != "true" {
+		http.Error(w, "Only dry runs are supported, pass dryRun=true", http.StatusBadRequest)
+		return
+	}
+
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var fullName, readme, manifest, preferredKey string
+	err := reader().QueryRow(`
+		SELECT full_name, COALESCE(readme_content, ''), COALESCE(manifest::text, ''), COALESCE(preferred_key, '')
+		FROM repositories WHERE id = $1
+	`, repoID).Scan(&fullName, &readme, &manifest, &preferredKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, r, http.StatusNotFound, "error", "Repository not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if strings.TrimSpace(readme) == "" {
+		http.Error(w, "Repository has no README to analyze", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !analyzeLimiter().Allow() {
+		http.Error(w, "Too many analysis requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	analysis, err := utils.AnalyzeWithOpenAI(r.Context(), openaiClient, fullName, readme, manifest)
+	if err != nil {
+		requestLogger(r).Error("Error analyzing repository", "repo", fullName, "error", err)
+		http.Error(w, fmt.Sprintf("Error analyzing repository: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	analysis.Configs = utils.DedupeConfigs(utils.DropInstallCommands(analysis.Configs))
+	utils.MarkChosenPreferred(analysis.Configs, preferredKey)
+	analysis.Category = utils.NormalizeCategories(analysis.Category)
+
+	writeJSON(w, r, analysis)
+}
@@ -0,0 +1,108 @@
+//go:build cgo
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// useOpenAI points openaiClient at a fake answering with respond for the duration of the test.
+func useOpenAI(t *testing.T, respond func(prompt string) *types.MCPServerManifest) {
+	t.Helper()
+
+	previous := openaiClient
+	openaiClient = newFakeOpenAI(t, respond)
+	t.Cleanup(func() { openaiClient = previous })
+}
+
+// postAnalyze posts readme to the analyze endpoint and returns the manifest it answers with.
+func postAnalyze(t *testing.T, name, readme string) types.MCPServerManifest {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"name": name, "readme": readme})
+	r := httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(string(body)))
+	r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+	w := httptest.NewRecorder()
+	analyzeReadmeHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api/analyze = %d %s", w.Code, w.Body.String())
+	}
+	var analysis types.MCPServerManifest
+	if err := json.Unmarshal(w.Body.Bytes(), &analysis); err != nil {
+		t.Fatal(err)
+	}
+	return analysis
+}
+
+func TestAnalyzeDropsInstallSteps(t *testing.T) {
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+
+	tests := []struct {
+		name   string
+		readme string
+		// configs is what the model extracts from the README, install steps included
+		configs []types.MCPServerConfig
+		want    []string
+	}{
+		{
+			name:   "owner/global-npm",
+			readme: "## Install\n\n```\nnpm install -g server-foo\n```\n\n## Run\n\n```\nserver-foo --stdio\n```",
+			configs: []types.MCPServerConfig{
+				{Command: "npm", Args: []string{"install", "-g", "server-foo"}},
+				{Command: "server-foo", Args: []string{"--stdio"}},
+			},
+			want: []string{"server-foo"},
+		},
+		{
+			name:   "owner/pip-then-uvx",
+			readme: "Install with `pip install mcp-foo` or `uv pip install mcp-foo`, then run `uvx mcp-foo`.",
+			configs: []types.MCPServerConfig{
+				{Command: "pip", Args: []string{"install", "mcp-foo"}},
+				{Command: "uv", Args: []string{"pip", "install", "mcp-foo"}},
+				{Command: "uvx", Args: []string{"mcp-foo"}},
+			},
+			want: []string{"uvx"},
+		},
+		{
+			name:   "owner/go-install",
+			readme: "```\ngo install github.com/owner/go-install@latest\n```\n\nAdd to mcpServers: `{\"command\": \"go-install\"}`",
+			configs: []types.MCPServerConfig{
+				{Command: "go install github.com/owner/go-install@latest"},
+				{Command: "go-install"},
+			},
+			want: []string{"go-install"},
+		},
+		{
+			name:    "owner/install-only",
+			readme:  "Run `npm i -g server-bar` to get started.",
+			configs: []types.MCPServerConfig{{Command: "npm", Args: []string{"i", "-g", "server-bar"}}},
+			want:    nil,
+		},
+	}
+	useOpenAI(t, func(prompt string) *types.MCPServerManifest {
+		for _, tt := range tests {
+			if strings.Contains(prompt, tt.readme) {
+				return &types.MCPServerManifest{Name: tt.name, Configs: tt.configs}
+			}
+		}
+		return nil
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, config := range postAnalyze(t, tt.name, tt.readme).Configs {
+				got = append(got, config.Command)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("configs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
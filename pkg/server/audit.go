@@ -0,0 +1,55 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// recordAudit stores a change to a repository in repository_audit. It should be called in the
+// same transaction as the change itself.
+func recordAudit(tx *sql.Tx, repoID int, action, oldValue, newValue string) error {
+	_, err := tx.Exec(`
+		INSERT INTO repository_audit (repo_id, action, old_value, new_value)
+		VALUES ($1, $2, $3, $4)
+	`, repoID, action, oldValue, newValue)
+	return err
+}
+
+func getRepoHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := reader().Query(`
+		SELECT id, repo_id, action, COALESCE(old_value, ''), COALESCE(new_value, ''), created_at
+		FROM repository_audit
+		WHERE repo_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, repoID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying repository history: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]types.AuditEntry, 0)
+	for rows.Next() {
+		var entry types.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.RepoID, &entry.Action, &entry.OldValue, &entry.NewValue, &entry.CreatedAt); err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning repository history: %v", err), http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repository history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, entries, len(entries))
+}
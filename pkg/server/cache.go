@@ -0,0 +1,32 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// cache keeps the result of an expensive computation, such as a catalog-wide aggregate, for ttl.
+type cache[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	value   T
+	expires time.Time
+}
+
+// get returns the cached value, calling compute when it is missing or expired. Errors are not cached.
+func (c *cache[T]) get(compute func() (T, error)) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.expires) {
+		return c.value, nil
+	}
+
+	value, err := compute()
+	if err != nil {
+		return value, err
+	}
+	c.value = value
+	c.expires = time.Now().Add(c.ttl)
+	return value, nil
+}
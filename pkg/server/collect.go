@@ -2,12 +2,17 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	pathpkg "path"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v60/github"
@@ -19,29 +24,138 @@ import (
 func startCronJobs() {
 	c := cron.New()
 
-	// Schedule collectData() to run every day at midnight
-	_, err := c.AddFunc("0 0 * * *", func() {
-		log.Println("Running scheduled daily data collection...")
-		go collectData(false)
+	// Schedule collectData() to run on SCRAPE_CRON, every day at midnight by default. "off"
+	// disables the scheduled scrape, for example on read replicas.
+	scrapeSchedule := os.Getenv("SCRAPE_CRON")
+	if scrapeSchedule == "" {
+		scrapeSchedule = "0 0 * * *"
+	}
+	if scrapeSchedule == "off" {
+		slog.Info("Scheduled scrape disabled")
+	} else {
+		if _, err := cron.ParseStandard(scrapeSchedule); err != nil {
+			fatal("Invalid SCRAPE_CRON", "schedule", scrapeSchedule, "error", err)
+		}
+		_, err := c.AddFunc(scrapeSchedule, func() {
+			slog.Info("Running scheduled data collection")
+			go collectData(shutdownCtx, false)
+		})
+		if err != nil {
+			fatal("Error scheduling cron job", "error", err)
+		}
+		slog.Info("Scheduled scrape", "schedule", scrapeSchedule)
+
+		// Instances that scrape also embed what was stored before embeddings were enabled
+		go backfillEmbeddings(shutdownCtx)
+	}
+
+	// Keep computed categories such as "Popular" in sync with star counts
+	refreshSchedule := os.Getenv("POPULAR_REFRESH_SCHEDULE")
+	if refreshSchedule == "" {
+		refreshSchedule = "@hourly"
+	}
+	_, err := c.AddFunc(refreshSchedule, func() {
+		if err := refreshComputedCategories(); err != nil {
+			slog.Error("Error refreshing computed categories", "error", err)
+		}
 	})
 	if err != nil {
-		log.Fatalf("Error scheduling cron job: %v", err)
+		fatal("Error scheduling computed category refresh", "error", err)
 	}
 
 	c.Start()
 }
 
-func collectData(force bool) {
-	ctx := context.Background()
-	log.Println("Searching repositories by README content...")
-	limit, _ := strconv.Atoi(os.Getenv("LIMIT"))
-	if limit == 0 {
-		limit = 4000
+const (
+	scrapeModeFull        = "full"
+	scrapeModeIncremental = "incremental"
+	scrapeModeCurated     = "curated"
+)
+
+// scrapeMode returns SCRAPE_MODE: "full" (the default) crawls GitHub code search, "incremental"
+// only refreshes the repositories that were scraped longest ago. Setting CURATED_LIST_FILE
+// selects "curated", which only processes the repositories listed in that file.
+func scrapeMode() string {
+	if os.Getenv("CURATED_LIST_FILE") != "" {
+		return scrapeModeCurated
+	}
+	switch mode := os.Getenv("SCRAPE_MODE"); mode {
+	case "", scrapeModeFull:
+		return scrapeModeFull
+	case scrapeModeIncremental:
+		return scrapeModeIncremental
+	default:
+		slog.Warn("Unknown SCRAPE_MODE, running a full scrape", "mode", mode)
+		return scrapeModeFull
 	}
-	searchReposByReadme(ctx, limit, force)
 }
 
-func searchReposByReadme(ctx context.Context, limit int, force bool) {
+// collectData runs a scrape in the configured mode. It stops early, recording the cancellation as
+// the scrape error, once ctx is done.
+func collectData(ctx context.Context, force bool) {
+	mode := scrapeMode()
+	budget := newTokenBudget()
+	ctx = budget.withContext(ctx)
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		*status = types.ScrapeStatus{
+			Running:     true,
+			Mode:        mode,
+			Force:       force,
+			StartedAt:   time.Now(),
+			TokenBudget: budget.limit,
+			Skipped:     map[string]int{},
+		}
+	})
+
+	var scrapeErr error
+	switch mode {
+	case scrapeModeCurated:
+		listFile := os.Getenv("CURATED_LIST_FILE")
+		slog.Info("Processing curated repositories", "force", force, "file", listFile)
+		scrapeErr = scrapeCuratedList(ctx, listFile, force, budget)
+	case scrapeModeIncremental:
+		limit, _ := strconv.Atoi(os.Getenv("SCRAPE_INCREMENTAL_LIMIT"))
+		if limit <= 0 {
+			limit = 100
+		}
+		slog.Info("Refreshing least recently scraped repositories", "force", force, "limit", limit)
+		scrapeErr = refreshStaleRepos(ctx, limit, force, budget)
+	default:
+		limit, _ := strconv.Atoi(os.Getenv("LIMIT"))
+		if limit == 0 {
+			limit = 4000
+		}
+		slog.Info("Searching repositories by README content", "force", force, "limit", limit)
+		scrapeErr = searchReposByReadme(ctx, limit, force, budget)
+	}
+
+	var status types.ScrapeStatus
+	updateScrapeStatus(func(s *types.ScrapeStatus) {
+		finishedAt := time.Now()
+		s.Running = false
+		s.FinishedAt = &finishedAt
+		s.TokensUsed = budget.used()
+		if scrapeErr != nil {
+			s.Error = scrapeErr.Error()
+		}
+		status = *s
+	})
+	for reason, count := range status.Skipped {
+		slog.Info("Skipped repositories", "reason", reason, "count", count)
+	}
+	if scrapeErr != nil {
+		slog.Error("Scrape finished with errors", "processed", status.Processed, "tokensUsed", status.TokensUsed, "error", scrapeErr)
+	} else {
+		slog.Info("Scrape finished", "processed", status.Processed, "tokensUsed", status.TokensUsed)
+	}
+	if status.SkippedForBudget > 0 {
+		slog.Warn("Token budget halted the scrape", "unprocessed", status.SkippedForBudget)
+	}
+}
+
+// searchReposByReadme finds and analyzes repositories. Errors for single repositories are logged
+// and skipped; the returned error reports whether the scrape as a whole was incomplete.
+func searchReposByReadme(ctx context.Context, limit int, force bool, budget *tokenBudget) error {
 	opts := &github.SearchOptions{
 		ListOptions: github.ListOptions{
 			PerPage: 1000,
@@ -58,26 +172,19 @@ func searchReposByReadme(ctx context.Context, limit int, force bool) {
 
 	// First get all repo links from these repos' READMEs
 	var repoLinks []string
+	var failedSeeds []string
 	for _, repoFullName := range reposToCheck {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("scrape cancelled: %w", err)
+		}
 		parts := strings.Split(repoFullName, "/")
 		owner, repo := parts[0], parts[1]
 
 		// Get README content
-		fileContent, _, _, err := githubClient.Repositories.GetContents(
-			ctx,
-			owner,
-			repo,
-			"README.md",
-			nil,
-		)
-		if err != nil {
-			log.Printf("Error getting README for %s: %v", repoFullName, err)
-			continue
-		}
-
-		content, err := fileContent.GetContent()
+		content, err := fetchSeedReadme(ctx, owner, repo)
 		if err != nil {
-			log.Printf("Error decoding README content for %s: %v", repoFullName, err)
+			slog.Warn("Seed repository could not be fetched, its links will be missing from this run", "repo", repoFullName, "error", err)
+			failedSeeds = append(failedSeeds, repoFullName)
 			continue
 		}
 
@@ -89,12 +196,18 @@ func searchReposByReadme(ctx context.Context, limit int, force bool) {
 			}
 		}
 	}
-	log.Printf("Found %d repos to check", len(repoLinks))
+	slog.Info("Found repos to check", "count", len(repoLinks))
+	if len(failedSeeds) > 0 {
+		slog.Warn("Some seed repositories could not be fetched", "failed", len(failedSeeds), "total", len(reposToCheck), "repos", strings.Join(failedSeeds, ", "))
+	}
 
 	// Now search for mcpServers in README of each repo found
 	// Process repos in batches of 30
 	batchSize := 15
 	for i := 0; i < len(repoLinks); i += batchSize {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("scrape cancelled: %w", err)
+		}
 		end := i + batchSize
 		if end > len(repoLinks) {
 			end = len(repoLinks)
@@ -106,23 +219,21 @@ func searchReposByReadme(ctx context.Context, limit int, force bool) {
 		}
 		query := fmt.Sprintf("%s mcpServers filename:README.md", strings.Join(queryParts, " "))
 
-		result, resp, err := githubClient.Search.Code(ctx, query, opts)
+		var result *github.CodeSearchResult
+		err := utils.GitHubSearchLimiter.Do(ctx, func() (resp *github.Response, err error) {
+			result, resp, err = githubClient.Search.Code(ctx, query, opts)
+			return resp, err
+		})
 		if err != nil {
-			if _, ok := err.(*github.RateLimitError); ok {
-				log.Printf("Hit rate limit, waiting for reset after time %s...\n", time.Until(resp.Rate.Reset.Time))
-				time.Sleep(time.Until(resp.Rate.Reset.Time))
-				continue
-			}
-			log.Printf("Error searching repositories: %v", err)
+			slog.Error("Error searching repositories", "query", query, "error", err)
 			continue
 		}
-		log.Printf("Found %d repos in batch %d", len(result.CodeResults), i/batchSize+1)
+		slog.Info("Found repos in batch", "count", len(result.CodeResults), "batch", i/batchSize+1)
 
 		allRepos = append(allRepos, result.CodeResults...)
 		if len(allRepos) >= limit {
 			break
 		}
-		time.Sleep(time.Second * 5)
 	}
 
 	// Search for repositories with "mcpServers" in their README files
@@ -132,26 +243,28 @@ func searchReposByReadme(ctx context.Context, limit int, force bool) {
 		if len(allRepos) >= limit {
 			break
 		}
-		result, resp, err := githubClient.Search.Code(ctx, query, opts)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("scrape cancelled: %w", err)
+		}
+		var (
+			result *github.CodeSearchResult
+			resp   *github.Response
+		)
+		err := utils.GitHubSearchLimiter.Do(ctx, func() (_ *github.Response, err error) {
+			result, resp, err = githubClient.Search.Code(ctx, query, opts)
+			return resp, err
+		})
 		if err != nil {
-			// Handle rate limiting
-			if _, ok := err.(*github.RateLimitError); ok {
-				log.Printf("Hit rate limit, waiting for reset after time %s...\n", time.Until(resp.Rate.Reset.Time))
-				time.Sleep(time.Until(resp.Rate.Reset.Time))
-				continue
-			}
-			log.Printf("Error searching repositories: %v", err)
-			return
+			return fmt.Errorf("error searching repositories: %v", err)
 		}
 
-		log.Printf("Found %d repositories", len(result.CodeResults))
+		slog.Info("Found repositories", "count", len(result.CodeResults))
 		allRepos = append(allRepos, result.CodeResults...)
 
 		if resp.NextPage == 0 {
 			break
 		}
 		opts.Page = resp.NextPage
-		time.Sleep(5 * time.Second)
 	}
 
 	// Deduplicate repositories based on fullname and path
@@ -168,34 +281,39 @@ func searchReposByReadme(ctx context.Context, limit int, force bool) {
 	}
 	allRepos = uniqueRepos
 
-	log.Printf("Found %d unique repositories", len(allRepos))
+	slog.Info("Found unique repositories", "count", len(allRepos))
 
-	// Process and store the repositories
-	addedRepos := make(map[string]bool)
-	for _, repo := range allRepos {
-		owner := *repo.Repository.Owner.Login
-		repoName := *repo.Repository.Name
-		path := repo.GetPath()
-		log.Printf("Processing repository: %s/%s/%s", owner, repoName, path)
-		addedRepoName, err := AddRepo(ctx, owner, repoName, path, force)
-		if err != nil {
-			log.Printf("Error processing repository %s: %v", *repo.Repository.FullName, err)
-			continue
+	var addedRepos map[string]bool
+	if samplingEnabled(budget) {
+		var err error
+		if addedRepos, err = scrapeSampled(ctx, allRepos, force, budget); err != nil {
+			return err
 		}
-		addedRepos[addedRepoName] = true
+	} else {
+		updateScrapeStatus(func(status *types.ScrapeStatus) {
+			status.Found = len(allRepos)
+		})
+		addedRepos, _ = processRepos(ctx, allRepos, force, budget)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("scrape cancelled: %w", err)
 	}
 
 	if force {
 		query := `
-		SELECT id, full_name, display_name, url, description, stars, readme_content, language, manifest, path, COALESCE(proposed_manifest, '{}'), COALESCE(tool_definitions, '{}'), COALESCE(icon, '')
+		SELECT id, full_name, display_name, url, description, stars, readme_content, language, manifest, path, COALESCE(proposed_manifest, '{}'), COALESCE(tool_definitions, '{}'), COALESCE(icon, ''), COALESCE(license, '')
 		FROM repositories
 	`
 		rows, err := db.Query(query)
 		if err != nil {
-			log.Fatalf("Error querying repositories: %v", err)
+			return fmt.Errorf("error querying repositories to update: %v", err)
 		}
-		defer rows.Close()
 
+		var failed int
+
+		// The repositories are read before any is updated, since SQLite can't write while a
+		// query is still open
+		var repos []types.RepoInfo
 		for rows.Next() {
 			var repo types.RepoInfo
 			err := rows.Scan(&repo.ID,
@@ -210,45 +328,411 @@ func searchReposByReadme(ctx context.Context, limit int, force bool) {
 				&repo.Path,
 				&repo.ProposedManifest,
 				&repo.ToolDefinitions,
-				&repo.Icon)
+				&repo.Icon,
+				&repo.License)
 			if err != nil {
-				log.Fatalf("Error scanning repository: %v", err)
+				slog.Error("Error scanning repository", "error", err)
+				failed++
+				continue
+			}
+			repos = append(repos, repo)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("error iterating repositories to update: %v", err)
+		}
+
+		for _, repo := range repos {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("scrape cancelled: %w", err)
 			}
 			if !addedRepos[repo.FullName] {
+				if budget.exhausted() {
+					updateScrapeStatus(func(status *types.ScrapeStatus) {
+						status.SkippedForBudget++
+					})
+					continue
+				}
+
 				var readme string
 				var metadata string
 				err = db.QueryRow("SELECT readme_content, metadata FROM repositories WHERE full_name = $1", repo.FullName).Scan(&readme, &metadata)
 				if err != nil {
-					log.Fatalf("Error getting readme from database: %v", err)
-					return
+					slog.Error("Error getting readme from database", "repo", repo.FullName, "error", err)
+					failed++
+					continue
 				}
+				repo.Metadata = metadata
+
+				slog.Info("Updating repository from existing database", "repo", repo.FullName)
+
+				_, err := utils.UpdateRepo(ctx, repo, force, openaiClient, repo.FullName, readme, db, githubClient)
+				if reason := skipReason(err); reason != "" {
+					recordSkip(reason)
+					slog.Debug("Skipping repository", "repo", repo.FullName, "reason", reason)
+				} else if err != nil {
+					slog.Error("Error updating repository", "repo", repo.FullName, "error", err)
+					failed++
+					continue
+				}
+				updateScrapeStatus(func(status *types.ScrapeStatus) {
+					status.Processed++
+					status.TokensUsed = budget.used()
+				})
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d existing repositories could not be updated", failed)
+		}
+	}
+	return nil
+}
 
-				log.Printf("Updating repository: %s from existing database", repo.FullName)
+// processRepos adds repositories through a bounded worker pool of SCRAPE_CONCURRENCY workers
+// (default 4), logging its progress periodically, and returns the names of the ones that were
+// added or updated. It stops handing out repositories once ctx is done; those and the ones the
+// budget didn't allow are returned as not started, keyed by full name and path.
+func processRepos(ctx context.Context, repos []*github.CodeResult, force bool, budget *tokenBudget) (addedRepos, notStarted map[string]bool) {
+	concurrency, _ := strconv.Atoi(os.Getenv("SCRAPE_CONCURRENCY"))
+	if concurrency <= 0 {
+		concurrency = 4
+	}
 
-				if _, err := utils.UpdateRepo(ctx, repo, force, openaiClient, repo.FullName, readme, db, githubClient); err != nil {
-					log.Fatalf("Error updating repository: %v", err)
-					return
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		jobs = make(chan *github.CodeResult)
+	)
+	addedRepos = make(map[string]bool)
+	notStarted = make(map[string]bool)
+
+	progress := newScrapeProgress(len(repos))
+	stopProgress := make(chan struct{})
+	go progress.report(stopProgress)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range jobs {
+				if ctx.Err() != nil {
+					progress.deferred.Add(1)
+					mu.Lock()
+					notStarted[candidateKey(repo)] = true
+					mu.Unlock()
+					continue
+				}
+				if budget.exhausted() {
+					updateScrapeStatus(func(status *types.ScrapeStatus) {
+						status.SkippedForBudget++
+					})
+					progress.deferred.Add(1)
+					mu.Lock()
+					notStarted[candidateKey(repo)] = true
+					mu.Unlock()
+					continue
+				}
+				addedRepoName, err := processRepo(ctx, repo, force)
+				updateScrapeStatus(func(status *types.ScrapeStatus) {
+					status.Processed++
+					status.TokensUsed = budget.used()
+				})
+				if reason := skipReason(err); reason != "" {
+					recordSkip(reason)
+					progress.skipped.Add(1)
+					utils.ReposScraped.WithLabelValues("skipped").Inc()
+					slog.Debug("Skipping repository", "repo", repo.GetRepository().GetFullName(), "path", repo.GetPath(), "reason", reason)
+					continue
+				}
+				if err != nil {
+					progress.failed.Add(1)
+					utils.ReposScraped.WithLabelValues("error").Inc()
+					slog.Error("Error processing repository", "repo", repo.GetRepository().GetFullName(), "path", repo.GetPath(), "error", err)
+					continue
 				}
+				if addedRepoName == "" {
+					progress.skipped.Add(1)
+					utils.ReposScraped.WithLabelValues("skipped").Inc()
+					continue
+				}
+				progress.added.Add(1)
+				utils.ReposScraped.WithLabelValues("added").Inc()
+				mu.Lock()
+				addedRepos[addedRepoName] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	fed := 0
+feed:
+	for _, repo := range repos {
+		select {
+		case jobs <- repo:
+			fed++
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(stopProgress)
+	for _, repo := range repos[fed:] {
+		notStarted[candidateKey(repo)] = true
+	}
+	progress.deferred.Add(int64(len(repos) - fed))
+	progress.log("Finished processing repositories")
+	return addedRepos, notStarted
+}
+
+// refreshStaleRepos re-fetches the limit repositories least recently attempted directly from
+// GitHub, without using code search. Every refresh that starts records last_refresh_attempt_at,
+// whether it succeeds or not, so that a repository that keeps failing goes to the back of the
+// queue instead of being retried first on every run. Repositories never attempted come first,
+// by oldest last_scraped_at.
+func refreshStaleRepos(ctx context.Context, limit int, force bool, budget *tokenBudget) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT full_name, COALESCE(path, '')
+		FROM repositories
+		ORDER BY last_refresh_attempt_at NULLS FIRST, last_scraped_at NULLS FIRST, full_name
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return fmt.Errorf("error querying stale repositories: %v", err)
+	}
+	defer rows.Close()
+
+	var (
+		repos     []*github.CodeResult
+		fullNames = make(map[string]string)
+	)
+	for rows.Next() {
+		var fullName, path string
+		if err := rows.Scan(&fullName, &path); err != nil {
+			return fmt.Errorf("error scanning stale repository: %v", err)
+		}
+		repo := codeResultFor(fullName, path)
+		if repo == nil {
+			slog.Warn("Skipping repository with invalid name", "repo", fullName)
+			continue
+		}
+		repos = append(repos, repo)
+		fullNames[candidateKey(repo)] = fullName
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating stale repositories: %v", err)
+	}
+	rows.Close()
+
+	slog.Info("Found stale repositories", "count", len(repos))
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		status.Found = len(repos)
+	})
+
+	_, notStarted := processRepos(ctx, repos, force, budget)
+	for key, fullName := range fullNames {
+		if notStarted[key] {
+			continue
+		}
+		if _, err := db.Exec(`UPDATE repositories SET last_refresh_attempt_at = CURRENT_TIMESTAMP WHERE full_name = $1`, fullName); err != nil {
+			slog.Error("Error recording refresh attempt", "repo", fullName, "error", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("scrape cancelled: %w", err)
+	}
+	return nil
+}
+
+// codeResultFor builds the code search result processRepos expects for the README at path in the
+// repository of the server fullName, or returns nil if fullName isn't owner/repo[/...].
+func codeResultFor(fullName, path string) *github.CodeResult {
+	parts := strings.SplitN(fullName, "/", 3)
+	if len(parts) < 2 {
+		return nil
+	}
+	if path == "" {
+		path = "README.md"
+	}
+	return &github.CodeResult{
+		Path: github.String(path),
+		Repository: &github.Repository{
+			FullName: github.String(parts[0] + "/" + parts[1]),
+			Name:     github.String(parts[1]),
+			Owner:    &github.User{Login: github.String(parts[0])},
+		},
+	}
+}
+
+// fetchSeedReadme fetches the README of a seed repository. Rate limits are handled by the
+// limiter; other transient failures are retried a few times since the seed links are high value.
+func fetchSeedReadme(ctx context.Context, owner, repo string) (string, error) {
+	const attempts = 3
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var fileContent *github.RepositoryContent
+		err = utils.GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+			fileContent, _, resp, err = githubClient.Repositories.GetContents(
+				ctx,
+				owner,
+				repo,
+				"README.md",
+				nil,
+			)
+			return resp, err
+		})
+		if err == nil {
+			return fileContent.GetContent()
+		}
+		if isNotFound(err) || ctx.Err() != nil {
+			return "", err
+		}
+
+		slog.Warn("Error getting README for seed", "repo", owner+"/"+repo, "attempt", attempt, "attempts", attempts, "error", err)
+		if attempt < attempts {
+			if err := utils.SleepContext(ctx, time.Duration(attempt)*5*time.Second); err != nil {
+				return "", err
 			}
 		}
 	}
+	return "", err
 }
 
+// processRepo adds a single code search result, recovering from panics so one bad
+// repository can't take down the rest of the worker pool.
+func processRepo(ctx context.Context, repo *github.CodeResult, force bool) (_ string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing repository: %v", r)
+		}
+	}()
+
+	owner := repo.GetRepository().GetOwner().GetLogin()
+	repoName := repo.GetRepository().GetName()
+	path := repo.GetPath()
+
+	// Code search returns the blob SHA of the README, so an unchanged README can be skipped
+	// without any further GitHub API calls
+	if !force && repo.GetSHA() != "" {
+		var readmeSHA string
+		fullName := serverFullName(repo.GetRepository().GetFullName(), path)
+		if err := db.QueryRow("SELECT COALESCE(readme_sha, '') FROM repositories WHERE full_name = $1", fullName).Scan(&readmeSHA); err == nil && readmeSHA == repo.GetSHA() {
+			db.Exec("UPDATE repositories SET last_scraped_at = CURRENT_TIMESTAMP WHERE full_name = $1", fullName)
+			slog.Debug("README unchanged, skipping", "repo", fullName)
+			recordSkip(skipReadmeUnchanged)
+			return "", nil
+		}
+	}
+
+	slog.Info("Processing repository", "repo", owner+"/"+repoName, "path", path)
+	return AddRepo(ctx, owner, repoName, path, force)
+}
+
+// serverFullName returns the normalized name of the server whose README is at path, e.g.
+// "owner/repo/src/github" for "src/github/README.md". A README in one of rootReadmeDirs documents
+// the repository itself, so "docs/README.md" is "owner/repo".
+func serverFullName(repoFullName, path string) string {
+	parts := strings.Split(utils.NormalizePath(path), "/")
+	if len(parts) > 1 && !isRootReadme(path) {
+		// Join all parts except the last one and append to fullName
+		repoFullName = repoFullName + "/" + strings.Join(parts[:len(parts)-1], "/")
+	}
+	return utils.NormalizeFullName(repoFullName)
+}
+
+// readmeCandidates are the paths tried, in order, when a repository's README has to be fetched directly.
+var readmeCandidates = []string{"README.md", "readme.md", "docs/README.md"}
+
+// rootReadmeDirs are the directories of readmeCandidates whose README describes the whole
+// repository rather than a server in that directory.
+var rootReadmeDirs = []string{"docs"}
+
+// isRootReadme reports whether the README at path describes the repository itself.
+func isRootReadme(path string) bool {
+	dir := pathpkg.Dir(utils.NormalizePath(path))
+	return dir == "." || slices.Contains(rootReadmeDirs, dir)
+}
+
+// addRepoFromReadme adds a repository that code search didn't find by fetching its README directly.
+func addRepoFromReadme(ctx context.Context, owner, repo string) (string, error) {
+	for _, path := range readmeCandidates {
+		addedRepoName, err := AddRepo(ctx, owner, repo, path, false)
+		if isNotFound(err) {
+			continue
+		}
+		return addedRepoName, err
+	}
+	return "", fmt.Errorf("no README found in repository %s/%s", owner, repo)
+}
+
+func isNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+func isNotModified(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotModified
+}
+
+// errNoMCPCommand is returned by AddRepo when the README doesn't mention any discovery keyword.
+var errNoMCPCommand = errors.New("README mentions no MCP command")
+
 func AddRepo(ctx context.Context, owner string, repo string, path string, force bool) (string, error) {
-	githubRepo, _, err := githubClient.Repositories.Get(ctx, owner, repo)
+	var githubRepo *github.Repository
+	err := utils.GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+		githubRepo, resp, err = githubClient.Repositories.Get(ctx, owner, repo)
+		return resp, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	path = utils.NormalizePath(path)
+	parts := strings.Split(path, "/")
+	fullName := serverFullName(*githubRepo.FullName, path)
+
+	var repoFromDB types.RepoInfo
+	err = db.QueryRow("SELECT readme_content, manifest, metadata, tool_definitions, COALESCE(icon, ''), COALESCE(analyzed_sha, ''), COALESCE(readme_sha, '') FROM repositories WHERE full_name = $1", fullName).Scan(&repoFromDB.ReadmeContent, &repoFromDB.Manifest, &repoFromDB.Metadata, &repoFromDB.ToolDefinitions, &repoFromDB.Icon, &repoFromDB.AnalyzedSHA, &repoFromDB.ReadmeSHA)
+	existsInDB := err == nil
+
+	// The default branch head identifies exactly which revision gets analyzed. Passing the last
+	// analyzed SHA makes this a conditional request: GitHub answers 304 when the head hasn't moved,
+	// which doesn't count against the rate limit.
+	var headSHA string
+	err = utils.GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+		headSHA, resp, err = githubClient.Repositories.GetCommitSHA1(ctx, *githubRepo.Owner.Login, *githubRepo.Name, githubRepo.GetDefaultBranch(), repoFromDB.AnalyzedSHA)
+		return resp, err
+	})
+	if isNotModified(err) {
+		headSHA, err = repoFromDB.AnalyzedSHA, nil
+	}
 	if err != nil {
 		return "", err
 	}
 
+	// Nothing in the repository changed since it was last analyzed
+	if existsInDB && !force && repoFromDB.AnalyzedSHA == headSHA {
+		backfillIcon(repoFromDB, githubRepo, fullName)
+		db.Exec("UPDATE repositories SET last_scraped_at = CURRENT_TIMESTAMP, pushed_at = $1 WHERE full_name = $2", pushedAt(githubRepo), fullName)
+		slog.Debug("Repository unchanged since last analyzed commit, skipping", "repo", fullName, "sha", headSHA)
+		recordSkip(skipCommitUnchanged)
+		return "", nil
+	}
+
 	// Get README content from the specific path where it was found
 	readmeContent := ""
-	fileContent, _, _, err := githubClient.Repositories.GetContents(
-		ctx,
-		*githubRepo.Owner.Login,
-		*githubRepo.Name,
-		path,
-		nil,
-	)
+	var fileContent *github.RepositoryContent
+	err = utils.GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+		fileContent, _, resp, err = githubClient.Repositories.GetContents(
+			ctx,
+			*githubRepo.Owner.Login,
+			*githubRepo.Name,
+			path,
+			nil,
+		)
+		return resp, err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -256,23 +740,32 @@ func AddRepo(ctx context.Context, owner string, repo string, path string, force
 	if err != nil {
 		return "", err
 	}
-
-	fullName := *githubRepo.FullName
-	parts := strings.Split(path, "/")
-	if len(parts) > 1 {
-		// Join all parts except the last one and append to fullName
-		fullName = fullName + "/" + strings.Join(parts[:len(parts)-1], "/")
-	}
+	readmeSHA := fileContent.GetSHA()
 
 	// Construct URL with correct path
 	repoURL := githubRepo.GetHTMLURL()
-	if len(parts) > 1 {
+	if len(parts) > 1 && !isRootReadme(path) {
 		// Add path components to URL, excluding the filename
 		repoURL = repoURL + "/tree/" + githubRepo.GetDefaultBranch() + "/" + strings.Join(parts[:len(parts)-1], "/")
 	}
 
-	if !strings.Contains(readmeContent, "mcpServers") && !strings.Contains(readmeContent, "npx") && !strings.Contains(readmeContent, "docker") && !strings.Contains(readmeContent, "uv") {
-		return "", fmt.Errorf("no MCP server found in repository %s", fullName)
+	if !mentionsMCPCommand(readmeContent) {
+		return "", fmt.Errorf("%w in repository %s", errNoMCPCommand, fullName)
+	}
+
+	readmeUnchanged := repoFromDB.ReadmeContent == readmeContent || (readmeSHA != "" && repoFromDB.ReadmeSHA == readmeSHA)
+	if existsInDB && readmeUnchanged && !force {
+		// Other files changed but the README didn't, so remember the new commit and skip the analysis
+		backfillIcon(repoFromDB, githubRepo, fullName)
+		db.Exec("UPDATE repositories SET analyzed_sha = $1, readme_sha = $2, last_scraped_at = CURRENT_TIMESTAMP, pushed_at = $3 WHERE full_name = $4", headSHA, readmeSHA, pushedAt(githubRepo), fullName)
+		slog.Debug("README unchanged, skipping", "repo", fullName)
+		recordSkip(skipReadmeUnchanged)
+		return "", nil
+	}
+
+	license, err := fetchLicense(ctx, *githubRepo.Owner.Login, *githubRepo.Name)
+	if err != nil {
+		return "", err
 	}
 
 	// Create RepoInfo
@@ -284,25 +777,136 @@ func AddRepo(ctx context.Context, owner string, repo string, path string, force
 		Stars:         githubRepo.GetStargazersCount(),
 		ReadmeContent: readmeContent,
 		Language:      githubRepo.GetLanguage(),
-		Icon:          githubRepo.GetOwner().GetAvatarURL(),
+		Icon:          repoIcon(readmeContent, githubRepo, path),
+		OwnerIcon:     githubRepo.GetOwner().GetAvatarURL(),
+		License:       license,
+		AnalyzedSHA:   headSHA,
+		ReadmeSHA:     readmeSHA,
 	}
+	repoInfo.Metadata = repoFromDB.Metadata
 
-	var repoFromDB types.RepoInfo
-	err = db.QueryRow("SELECT readme_content, manifest, metadata, tool_definitions, icon FROM repositories WHERE full_name = $1", fullName).Scan(&repoFromDB.ReadmeContent, &repoFromDB.Manifest, &repoFromDB.Metadata, &repoFromDB.ToolDefinitions, &repoFromDB.Icon)
+	savedName, err := utils.UpdateRepo(ctx, repoInfo, force, openaiClient, fullName, readmeContent, db, githubClient)
 	if err == nil {
-		if repoFromDB.ReadmeContent == readmeContent && !force {
-			// Repository exists in DB, skip it, unless it doesn't have an icon and we need to add it.
-			if repoFromDB.Icon == "" {
-				// now update in db
-				db.Exec("UPDATE repositories SET icon = $1 WHERE full_name = $2", githubRepo.GetOwner().GetAvatarURL(), fullName)
-				log.Printf("Updated icon for repository %s", fullName)
-			}
+		db.Exec("UPDATE repositories SET pushed_at = $1 WHERE full_name = $2", pushedAt(githubRepo), savedName)
+	}
+	return savedName, err
+}
 
-			log.Printf("Repository %s already exists in database, skipping", fullName)
-			return "", nil
+// pushedAt returns when the repository was last pushed to, which ranks it when a scrape samples,
+// or nil if GitHub didn't say.
+func pushedAt(githubRepo *github.Repository) *time.Time {
+	pushed := githubRepo.GetPushedAt().Time
+	if pushed.IsZero() {
+		return nil
+	}
+	return &pushed
+}
+
+// defaultDiscoveryKeywords are the words a README must mention for the repository to be analyzed:
+// the mcpServers config key or one of the commands used to launch a server.
+var defaultDiscoveryKeywords = []string{"mcpServers", "npx", "uv", "uvx", "pipx", "docker"}
+
+// discoveryPattern matches any discovery keyword as a whole word, so "uv" doesn't match "service".
+// The keywords can be replaced with the comma separated DISCOVERY_KEYWORDS.
+var discoveryPattern = sync.OnceValue(func() *regexp.Regexp {
+	var keywords []string
+	for _, keyword := range strings.Split(os.Getenv("DISCOVERY_KEYWORDS"), ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
 		}
 	}
-	repoInfo.Metadata = repoFromDB.Metadata
+	if len(keywords) == 0 {
+		keywords = defaultDiscoveryKeywords
+	}
+
+	quoted := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		quoted = append(quoted, regexp.QuoteMeta(keyword))
+	}
+	return regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+})
+
+// maxGateScan bounds how much README text the discovery keywords are searched in. Install
+// instructions come well before this in any README.
+const maxGateScan = 256 << 10
 
-	return utils.UpdateRepo(ctx, repoInfo, force, openaiClient, fullName, readmeContent, db, githubClient)
+// encodedBlob matches long runs without whitespace that can't be prose, like raw base64.
+var encodedBlob = regexp.MustCompile(`[A-Za-z0-9+/=]{200,}`)
+
+// mentionsMCPCommand reports whether a README mentions any of the discovery keywords. Only the
+// first maxGateScan bytes of text are searched, leaving out inline data such as base64 images.
+func mentionsMCPCommand(readmeContent string) bool {
+	return discoveryPattern().MatchString(gateText(readmeContent))
+}
+
+// gateText returns the text of a README the discovery keywords are searched in. Data URIs are
+// skipped while the README is walked, so images at the top don't use up the window and the
+// README is never copied as a whole.
+func gateText(readmeContent string) string {
+	var text strings.Builder
+	for rest := readmeContent; rest != "" && text.Len() < maxGateScan; {
+		i := strings.Index(rest, "data:")
+		if i < 0 {
+			i = len(rest)
+		}
+		text.WriteString(rest[:min(i, maxGateScan-text.Len())])
+		if i == len(rest) {
+			break
+		}
+		rest = rest[i+len("data:"):]
+		end := strings.IndexFunc(rest, func(r rune) bool { return !isDataURIChar(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		rest = rest[end:]
+		text.WriteByte(' ')
+	}
+	return encodedBlob.ReplaceAllString(text.String(), "")
+}
+
+// isDataURIChar reports whether r can appear in a data URI: its media type, parameters and base64
+// or percent encoded data.
+func isDataURIChar(r rune) bool {
+	return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("+/=%;,.:_-", r)
+}
+
+// backfillIcon adds the owner avatar to a stored repository that doesn't have an icon yet.
+func backfillIcon(repoFromDB types.RepoInfo, githubRepo *github.Repository, fullName string) {
+	if repoFromDB.Icon == "" {
+		db.Exec("UPDATE repositories SET icon = $1, owner_icon = $1 WHERE full_name = $2", githubRepo.GetOwner().GetAvatarURL(), fullName)
+		slog.Info("Updated icon", "repo", fullName)
+	}
+}
+
+// repoIcon returns the icon specified by the README at path, falling back to the owner avatar.
+func repoIcon(readmeContent string, githubRepo *github.Repository, path string) string {
+	rawBase := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/", githubRepo.GetFullName(), githubRepo.GetDefaultBranch())
+	if dir := pathpkg.Dir(path); dir != "." {
+		rawBase += dir + "/"
+	}
+	if icon := utils.ReadmeIcon(readmeContent, rawBase); icon != "" {
+		return icon
+	}
+	return githubRepo.GetOwner().GetAvatarURL()
+}
+
+// fetchLicense returns the SPDX id of the repository's license, or an empty string if
+// GitHub couldn't detect one.
+func fetchLicense(ctx context.Context, owner, repo string) (string, error) {
+	var repoLicense *github.RepositoryLicense
+	err := utils.GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+		repoLicense, resp, err = githubClient.Repositories.License(ctx, owner, repo)
+		return resp, err
+	})
+	if isNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	spdxID := repoLicense.GetLicense().GetSPDXID()
+	if spdxID == "NOASSERTION" {
+		return "", nil
+	}
+	return spdxID, nil
 }
@@ -0,0 +1,135 @@
+//go:build cgo
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+func TestRefreshStaleReposRecordsFailedAttempts(t *testing.T) {
+	newTestDB(t)
+	newFakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	insertTestRepo(t, "owner/broken", map[string]any{"last_scraped_at": "2020-01-01 00:00:00"})
+	insertTestRepo(t, "owner/next", map[string]any{"last_scraped_at": "2024-01-01 00:00:00"})
+
+	attempted := func(fullName string) (attempt sql.NullString, scraped string) {
+		t.Helper()
+		if err := db.QueryRow(`SELECT last_refresh_attempt_at, last_scraped_at FROM repositories WHERE full_name = $1`, fullName).
+			Scan(&attempt, &scraped); err != nil {
+			t.Fatal(err)
+		}
+		return attempt, scraped
+	}
+
+	// The oldest repository is refreshed first and its failure is recorded as an attempt
+	if err := refreshStaleRepos(context.Background(), 1, false, newTokenBudget()); err != nil {
+		t.Fatalf("refreshStaleRepos() error = %v", err)
+	}
+	attempt, scraped := attempted("owner/broken")
+	if !attempt.Valid {
+		t.Error("failed refresh of owner/broken was not recorded")
+	}
+	if scraped != "2020-01-01T00:00:00Z" {
+		t.Errorf("last_scraped_at of owner/broken = %q, want it unchanged", scraped)
+	}
+	if attempt, _ := attempted("owner/next"); attempt.Valid {
+		t.Error("owner/next was attempted in the first run")
+	}
+
+	// The next run moves on instead of retrying the failing repository
+	if err := refreshStaleRepos(context.Background(), 1, false, newTokenBudget()); err != nil {
+		t.Fatalf("refreshStaleRepos() error = %v", err)
+	}
+	if attempt, _ := attempted("owner/next"); !attempt.Valid {
+		t.Error("second run did not move on to owner/next")
+	}
+}
+
+func TestProcessRepoUnchangedReadmeIsScraped(t *testing.T) {
+	newTestDB(t)
+	insertTestRepo(t, "owner/repo", map[string]any{"readme_sha": "abc", "last_scraped_at": "2020-01-01 00:00:00"})
+
+	// The README SHA from code search matches, so no GitHub client is needed
+	result := codeResultFor("owner/repo", "README.md")
+	result.SHA = github.String("abc")
+	if name, err := processRepo(context.Background(), result, false); err != nil || name != "" {
+		t.Fatalf("processRepo() = %q, %v, want an unchanged skip", name, err)
+	}
+
+	var scraped string
+	if err := db.QueryRow(`SELECT last_scraped_at FROM repositories WHERE full_name = 'owner/repo'`).Scan(&scraped); err != nil {
+		t.Fatal(err)
+	}
+	if scraped == "2020-01-01T00:00:00Z" {
+		t.Error("last_scraped_at was not updated for the unchanged README")
+	}
+}
+
+func TestServerFullName(t *testing.T) {
+	tests := map[string]string{
+		"README.md":               "owner/repo",
+		"readme.md":               "owner/repo",
+		"docs/README.md":          "owner/repo",
+		"src/github/README.md":    "owner/repo/src/github",
+		"packages/docs/README.md": "owner/repo/packages/docs",
+		"/Src//GitHub/README.md":  "owner/repo/Src/GitHub",
+		"docs/guides/README.md":   "owner/repo/docs/guides",
+	}
+	for path, want := range tests {
+		if got := serverFullName("Owner/Repo", path); got != want {
+			t.Errorf("serverFullName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestForcedScrapeSkipsExistingWithoutServer(t *testing.T) {
+	newTestDB(t)
+	newFakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	useOpenAI(t, func(string) *types.MCPServerManifest { return &types.MCPServerManifest{} })
+	insertTestRepo(t, "owner/not-a-server", map[string]any{"readme_content": "A library", "metadata": "{}"})
+
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		*status = types.ScrapeStatus{Running: true, Skipped: map[string]int{}}
+	})
+	t.Cleanup(func() {
+		updateScrapeStatus(func(status *types.ScrapeStatus) { *status = types.ScrapeStatus{} })
+	})
+
+	// The seeds can't be fetched and nothing is searched, so only the stored repository is updated
+	if err := searchReposByReadme(context.Background(), 0, true, newTokenBudget()); err != nil {
+		t.Fatalf("searchReposByReadme() error = %v, want the repository without a server skipped", err)
+	}
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		if status.Skipped[skipAnalysisEmpty] != 1 {
+			t.Errorf("skipped = %v, want 1 %s", status.Skipped, skipAnalysisEmpty)
+		}
+	})
+}
+
+func TestFetchSeedReadmeStopsWhenCancelled(t *testing.T) {
+	newFakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := fetchSeedReadme(ctx, "owner", "seed"); err == nil {
+		t.Fatal("fetchSeedReadme() succeeded against a failing GitHub")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("fetchSeedReadme() returned after %s, want it to stop waiting when cancelled", elapsed)
+	}
+}
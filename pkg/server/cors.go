@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// corsMiddleware allows the origins in the comma separated CORS_ALLOWED_ORIGINS (default: the
+// local frontend) to call the API. The request's origin is echoed back rather than a wildcard so
+// that, unless CORS_ALLOW_CREDENTIALS=false, browsers send the auth cookie along. Configuring "*"
+// allows any origin without credentials.
+func corsMiddleware(next http.Handler) http.Handler {
+	allowedOrigins := []string{"http://localhost:5175"}
+	if env := os.Getenv("CORS_ALLOWED_ORIGINS"); env != "" {
+		allowedOrigins = nil
+		for _, origin := range strings.Split(env, ",") {
+			if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+				allowedOrigins = append(allowedOrigins, origin)
+			}
+		}
+	}
+	anyOrigin := slices.Contains(allowedOrigins, "*")
+	allowCredentials := os.Getenv("CORS_ALLOW_CREDENTIALS") != "false" && !anyOrigin
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		switch {
+		case anyOrigin:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(allowedOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Total-Count")
+
+		// Handle preflight requests
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// preflight sends an OPTIONS preflight from origin through corsMiddleware and reports whether the
+// request reached the wrapped handler.
+func preflight(t *testing.T, origin string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+
+	reached := false
+	handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	r := httptest.NewRequest(http.MethodOptions, "/api/repos/1/approve", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	r.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, reached
+}
+
+func TestCORSPreflight(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://admin.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "")
+
+	w, reached := preflight(t, "https://admin.example.com")
+	if reached {
+		t.Error("preflight was passed on to the handler")
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+	header := w.Header()
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request's origin", got)
+	}
+	if got := header.Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true so the auth cookie is sent", got)
+	}
+	if got := header.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Authorization") || !strings.Contains(got, "Content-Type") {
+		t.Errorf("Access-Control-Allow-Headers = %q, want Authorization and Content-Type", got)
+	}
+	if got := header.Get("Access-Control-Allow-Methods"); !strings.Contains(got, http.MethodPost) {
+		t.Errorf("Access-Control-Allow-Methods = %q, want POST", got)
+	}
+	if got := header.Values("Vary"); len(got) == 0 || got[0] != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+}
+
+func TestCORSPreflightOtherOrigin(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://admin.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "")
+
+	w, _ := preflight(t, "https://evil.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for an origin that isn't allowed", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q for an origin that isn't allowed", got)
+	}
+}
+
+func TestCORSCredentialsConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         string
+		credentials     string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{name: "default frontend", wantOrigin: "http://localhost:5175", wantCredentials: "true"},
+		{name: "configured origin", origins: "https://a.example.com, https://admin.example.com/", wantOrigin: "https://admin.example.com", wantCredentials: "true"},
+		{name: "credentials turned off", origins: "https://admin.example.com", credentials: "false", wantOrigin: "https://admin.example.com"},
+		{name: "any origin never allows credentials", origins: "*", wantOrigin: "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_ALLOWED_ORIGINS", tt.origins)
+			t.Setenv("CORS_ALLOW_CREDENTIALS", tt.credentials)
+
+			origin := tt.wantOrigin
+			if origin == "*" {
+				origin = "https://anywhere.example.com"
+			}
+			w, _ := preflight(t, origin)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+		})
+	}
+}
+
+func TestCORSCredentialedRequest(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://admin.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "")
+
+	var cookie string
+	handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("obot-catalog-server-token"); err == nil {
+			cookie = c.Value
+		}
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/api/repos/1/approve", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if cookie != "secret" {
+		t.Errorf("handler got cookie %q, want it passed through", cookie)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("response headers %v don't let the browser read a credentialed response", w.Header())
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(got, "X-Total-Count") {
+		t.Errorf("Access-Control-Expose-Headers = %q, want X-Total-Count", got)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// readCuratedList reads the allowlist at path: one owner/repo[/subpath] per line, where subpath is
+// the directory of the server's README, or the README itself if it ends in .md. Blank lines and
+// lines starting with # are ignored.
+func readCuratedList(path string) ([]*github.CodeResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		repos []*github.CodeResult
+		seen  = make(map[string]bool)
+	)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.Trim(strings.TrimSpace(scanner.Text()), "/")
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "https://"), "github.com/")
+
+		parts := strings.SplitN(entry, "/", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("line %d: %q is not owner/repo[/subpath]", line, entry)
+		}
+		readme := "README.md"
+		if len(parts) == 3 {
+			readme = parts[2]
+			if !strings.HasSuffix(strings.ToLower(readme), ".md") {
+				readme = pathpkg.Join(readme, "README.md")
+			}
+		}
+
+		repo := codeResultFor(parts[0]+"/"+parts[1], readme)
+		if key := candidateKey(repo); !seen[key] {
+			seen[key] = true
+			repos = append(repos, repo)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// scrapeCuratedList processes only the repositories in CURATED_LIST_FILE, without any GitHub
+// search. Repositories already in the catalog that aren't listed are left as they are.
+func scrapeCuratedList(ctx context.Context, listFile string, force bool, budget *tokenBudget) error {
+	repos, err := readCuratedList(listFile)
+	if err != nil {
+		return fmt.Errorf("error reading CURATED_LIST_FILE: %v", err)
+	}
+
+	slog.Info("Found curated repositories", "count", len(repos), "file", listFile)
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		status.Found = len(repos)
+	})
+
+	processRepos(ctx, repos, force, budget)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("scrape cancelled: %w", err)
+	}
+	return nil
+}
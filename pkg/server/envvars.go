@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+var envVarsCache = &cache[[]types.EnvVarUsage]{ttl: 10 * time.Minute}
+
+// getEnvVarsHandler lists the distinct env var keys used by the stored manifests with the number
+// of repositories using each, most common first, so shared credentials can be entered once.
+func getEnvVarsHandler(w http.ResponseWriter, r *http.Request) {
+	envVars, err := envVarsCache.get(countEnvVars)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error counting env vars: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, envVars, len(envVars))
+}
+
+func countEnvVars() ([]types.EnvVarUsage, error) {
+	rows, err := reader().Query(`
+		SELECT key,
+			COALESCE((
+				SELECT name FROM manifest_env names
+				WHERE names.key = manifest_env.key AND name <> ''
+				GROUP BY name
+				ORDER BY COUNT(*) DESC, name
+				LIMIT 1
+			), ''),
+			COUNT(*),
+			bool_or(sensitive)
+		FROM manifest_env
+		GROUP BY key
+		ORDER BY COUNT(*) DESC, key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	envVars := make([]types.EnvVarUsage, 0)
+	for rows.Next() {
+		var envVar types.EnvVarUsage
+		if err := rows.Scan(&envVar.Key, &envVar.Name, &envVar.Count, &envVar.Sensitive); err != nil {
+			return nil, err
+		}
+		envVars = append(envVars, envVar)
+	}
+	return envVars, rows.Err()
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// exportRepoHandler returns a repository's approved manifest in another registry's format,
+// selected with ?format=. Only "smithery" is supported.
+func exportRepoHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "smithery" {
+		http.Error(w, fmt.Sprintf("Unsupported export format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		repo     types.RepoInfo
+		manifest string
+	)
+	err := reader().QueryRow(`
+		SELECT full_name, COALESCE(display_name, ''), COALESCE(description, ''), COALESCE(url, ''), COALESCE(icon, ''), COALESCE(manifest::text, '')
+		FROM repositories
+		WHERE id = $1
+	`, repoID).Scan(&repo.FullName, &repo.DisplayName, &repo.Description, &repo.URL, &repo.Icon, &manifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	configs, err := utils.ParseManifest(manifest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored manifest: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	if len(configs) == 0 {
+		http.Error(w, "Repository has no manifest", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(utils.ToSmithery(repo, configs))
+}
+
+// getRepoMCPConfigHandler returns a config of the approved manifest as a ready to fill in
+// mcpServers object. ?config= selects a config by index; by default the preferred one is used.
+func getRepoMCPConfigHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		repo     types.RepoInfo
+		manifest string
+	)
+	err := reader().QueryRow(`
+		SELECT full_name, COALESCE(manifest::text, '')
+		FROM repositories
+		WHERE id = $1
+	`, repoID).Scan(&repo.FullName, &manifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	configs, err := utils.ParseManifest(manifest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored manifest: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	if len(configs) == 0 {
+		http.Error(w, "Repository has no manifest", http.StatusNotFound)
+		return
+	}
+
+	config, _ := utils.PreferredConfig(configs)
+	if raw := r.URL.Query().Get("config"); raw != "" {
+		index, err := strconv.Atoi(raw)
+		if err != nil || index < 0 || index >= len(configs) {
+			http.Error(w, fmt.Sprintf("Invalid config %q: must be an index between 0 and %d", raw, len(configs)-1), http.StatusBadRequest)
+			return
+		}
+		config = configs[index]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(utils.ToMCPConfig(repo, config))
+}
+
+// getRepoToolsHandler returns the tool definitions scraped for a repository. By default they are
+// returned as {"tools": [...]} with MCP tool fields. ?format=openai-functions converts them
+// to OpenAI function calling tools instead: "tools" can be passed to the chat completions API as
+// is, and "skipped" lists the tools that could not be converted and why.
+func getRepoToolsHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "mcp" && format != "openai-functions" {
+		http.Error(w, fmt.Sprintf("Unsupported tools format %q: must be mcp or openai-functions", format), http.StatusBadRequest)
+		return
+	}
+
+	var toolDefinitions string
+	err := reader().QueryRow(`SELECT COALESCE(tool_definitions::text, '{}') FROM repositories WHERE id = $1`, repoID).Scan(&toolDefinitions)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The scraper stores a plain array of tools; rows that were never scraped hold an empty object
+	var tools types.ToolResponse
+	if trimmed := strings.TrimSpace(toolDefinitions); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal([]byte(trimmed), &tools.Tools)
+	} else {
+		err = json.Unmarshal([]byte(trimmed), &tools)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored tool definitions: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	if tools.Tools == nil {
+		tools.Tools = []types.MCPTool{}
+	}
+
+	if format != "openai-functions" {
+		writeJSON(w, r, tools)
+		return
+	}
+	converted, skipped := utils.ToOpenAIFunctions(tools.Tools)
+	if len(skipped) > 0 {
+		requestLogger(r).Debug("Skipped tools in OpenAI conversion", "id", repoID, "skipped", len(skipped))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"tools":   converted,
+		"skipped": skipped,
+	})
+}
+
+// exportCatalogHandler streams every repository, including the stored manifests, metadata and
+// tool definitions, as a JSON array for backups and mirroring. ?since= (RFC 3339 or YYYY-MM-DD)
+// limits the export to repositories changed after that time. Rows are written as they are read,
+// so a failure halfway through leaves a truncated array; it is logged.
+func exportCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			if since, err = time.Parse(time.DateOnly, raw); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid since %q: must be an RFC 3339 timestamp or a date", raw), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	rows, err := reader().QueryContext(r.Context(), `
+		SELECT id, COALESCE(path, ''), full_name, COALESCE(display_name, ''), COALESCE(url, ''), COALESCE(description, ''),
+			COALESCE(stars, 0), COALESCE(readme_content, ''), COALESCE(language, ''), COALESCE(metadata::text, '{}'),
+			COALESCE(license, ''), COALESCE(icon, ''), COALESCE(owner_icon, ''), COALESCE(manifest::text, '{}'),
+			COALESCE(proposed_manifest::text, ''), COALESCE(staging_manifest::text, ''), COALESCE(tool_definitions::text, '{}'),
+			version, COALESCE(analyzed_sha, ''), COALESCE(readme_sha, ''), deprecated, COALESCE(superseded_by, ''),
+			COALESCE(preferred_key, ''), platforms, last_scraped_at, created_at, updated_at
+		FROM repositories
+		WHERE updated_at > $1
+		ORDER BY id
+	`, since)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	logger := requestLogger(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("[\n"))
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	for rows.Next() {
+		var repo types.RepoInfo
+		if err := rows.Scan(&repo.ID, &repo.Path, &repo.FullName, &repo.DisplayName, &repo.URL, &repo.Description,
+			&repo.Stars, &repo.ReadmeContent, &repo.Language, &repo.Metadata,
+			&repo.License, &repo.Icon, &repo.OwnerIcon, &repo.Manifest,
+			&repo.ProposedManifest, &repo.StagingManifest, &repo.ToolDefinitions,
+			&repo.Version, &repo.AnalyzedSHA, &repo.ReadmeSHA, &repo.Deprecated, &repo.SupersededBy,
+			&repo.PreferredKey, &repo.Platforms, &repo.LastScrapedAt, &repo.CreatedAt, &repo.UpdatedAt); err != nil {
+			logger.Error("Error scanning repository, export is truncated", "exported", count, "error", err)
+			return
+		}
+
+		if count > 0 {
+			w.Write([]byte(","))
+		}
+		if err := encoder.Encode(repo); err != nil {
+			logger.Error("Error writing export, export is truncated", "exported", count, "error", err)
+			return
+		}
+		count++
+		if count%100 == 0 {
+			http.NewResponseController(w).Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating repositories, export is truncated", "exported", count, "error", err)
+		return
+	}
+
+	w.Write([]byte("]\n"))
+	logger.Info("Exported catalog", "repositories", count, "since", since)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// getFailedAnalysisHandler lists repositories that passed the scrape filters but whose analysis
+// produced no manifest, most attempts first. ?minAttempts= hides repos that only failed a few times.
+func getFailedAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+
+	minAttempts := 1
+	if val, err := strconv.Atoi(r.URL.Query().Get("minAttempts")); err == nil && val > 0 {
+		minAttempts = val
+	}
+
+	var totalCount int
+	if err := reader().QueryRow(`SELECT COUNT(*) FROM analysis_failures WHERE analysis_attempts >= $1`, minAttempts).Scan(&totalCount); err != nil {
+		http.Error(w, fmt.Sprintf("Error counting failed analyses: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := reader().Query(`
+		SELECT full_name, COALESCE(url, ''), analysis_attempts, COALESCE(last_analysis_error, ''), last_attempt_at
+		FROM analysis_failures
+		WHERE analysis_attempts >= $1
+		ORDER BY analysis_attempts DESC, full_name
+		LIMIT $2 OFFSET $3
+	`, minAttempts, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying failed analyses: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	failures := make([]types.AnalysisFailure, 0)
+	for rows.Next() {
+		var failure types.AnalysisFailure
+		if err := rows.Scan(&failure.FullName, &failure.URL, &failure.AnalysisAttempts, &failure.LastAnalysisError, &failure.LastAttemptAt); err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning failed analysis: %v", err), http.StatusInternalServerError)
+			return
+		}
+		failures = append(failures, failure)
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating failed analyses: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, failures, totalCount)
+}
+
+// clearFailedAnalysisHandler resets the failure record of ?fullName=, or of every repository when
+// it is omitted, e.g. after the prompt has been fixed.
+func clearFailedAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := `DELETE FROM analysis_failures`
+	var args []interface{}
+	if fullName := r.URL.Query().Get("fullName"); fullName != "" {
+		query += ` WHERE full_name = $1`
+		args = append(args, utils.NormalizeFullName(fullName))
+	}
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error clearing failed analyses: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	cleared, _ := result.RowsAffected()
+	writeJSON(w, r, map[string]int64{"cleared": cleared})
+}
@@ -0,0 +1,132 @@
+//go:build cgo
+
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestDB points db at a fresh, fully migrated SQLite database for the duration of the test.
+func newTestDB(t *testing.T) {
+	t.Helper()
+
+	dbDriver = "sqlite3"
+	conn, err := openDB("file:" + filepath.Join(t.TempDir(), "catalog.db") + "?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	db, readDB = conn, nil
+	t.Cleanup(func() {
+		conn.Close()
+		db = nil
+	})
+
+	if err := applyMigrations(); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+}
+
+// newTestReadDB points readDB at a second fresh, fully migrated SQLite database, leaving db as it
+// is. Rows inserted through db then only show up in handlers that read from the primary.
+func newTestReadDB(t *testing.T) {
+	t.Helper()
+
+	primary := db
+	newTestDB(t)
+	readDB, db = db, primary
+	t.Cleanup(func() { readDB = nil })
+}
+
+// insertTestRepo stores a repository with the given columns and returns its id. Columns the
+// scraper always fills in default to empty values.
+func insertTestRepo(t *testing.T, fullName string, columns map[string]any) int {
+	t.Helper()
+
+	values := map[string]any{
+		"path":           "",
+		"display_name":   fullName,
+		"url":            "https://github.com/" + fullName,
+		"description":    "",
+		"stars":          0,
+		"readme_content": "",
+		"language":       "",
+		"manifest":       "{}",
+	}
+	maps.Copy(values, columns)
+
+	names, placeholders, args := "full_name", "$1", []any{fullName}
+	for name, value := range values {
+		args = append(args, value)
+		names += ", " + name
+		placeholders += ", $" + strconv.Itoa(len(args))
+	}
+	var id int
+	if err := db.QueryRow(`INSERT INTO repositories (`+names+`) VALUES (`+placeholders+`) RETURNING id`, args...).Scan(&id); err != nil {
+		t.Fatalf("inserting %s: %v", fullName, err)
+	}
+	return id
+}
+
+// newFakeOpenAI returns a client whose chat completions are answered by respond. A nil manifest
+// makes the fake answer with a 400 error, which is not retried.
+func newFakeOpenAI(t *testing.T, respond func(prompt string) *types.MCPServerManifest) *openai.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the messages are decoded; the response format doesn't unmarshal into its Go type
+		var request struct {
+			Messages []openai.ChatCompletionMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		manifest := respond(request.Messages[len(request.Messages)-1].Content)
+		if manifest == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"model failed","type":"invalid_request_error"}}`))
+			return
+		}
+		content, _ := json.Marshal(manifest)
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: string(content)},
+			}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = srv.URL + "/v1"
+	return openai.NewClientWithConfig(config)
+}
+
+// newFakeGitHub points githubClient at handler for the duration of the test.
+func newFakeGitHub(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = baseURL
+	previous := githubClient
+	githubClient = client
+	t.Cleanup(func() { githubClient = previous })
+}
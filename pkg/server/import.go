@@ -0,0 +1,129 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+type importSummary struct {
+	Inserted int      `json:"inserted"`
+	Updated  int      `json:"updated"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// maxImportBytes caps the size of an import body.
+const maxImportBytes = 256 << 20
+
+// importCatalogHandler seeds the catalog from the output of GET /api/export, upserting by
+// full_name. The scraped fields of existing repositories are always updated; their manifests are
+// kept unless ?overwrite=true, in which case the imported manifests replace them. An entry that
+// can't be decoded is reported in the summary instead of failing the entries already imported.
+func importCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		http.Error(w, "Request body must be a JSON array of repositories", http.StatusBadRequest)
+		return
+	}
+
+	logger := requestLogger(r)
+	summary := importSummary{}
+	for position := 0; decoder.More(); position++ {
+		var repo types.RepoInfo
+		if err := decoder.Decode(&repo); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("repository at position %d: %v", position, err))
+			summary.Skipped++
+			// A value of the wrong type is consumed whole, anything else leaves the rest of the
+			// body unreadable
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				continue
+			}
+			break
+		}
+		if strings.TrimSpace(repo.FullName) == "" {
+			summary.Skipped++
+			continue
+		}
+		repo.FullName = utils.NormalizeFullName(repo.FullName)
+
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM repositories WHERE full_name = $1)`, repo.FullName).Scan(&exists); err != nil {
+			logger.Error("Error checking repository", "repo", repo.FullName, "error", err)
+			summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", repo.FullName, err))
+			summary.Skipped++
+			continue
+		}
+
+		if err := importRepo(repo, exists && !overwrite); err != nil {
+			logger.Error("Error importing repository", "repo", repo.FullName, "error", err)
+			summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", repo.FullName, err))
+			summary.Skipped++
+			continue
+		}
+		if exists {
+			summary.Updated++
+		} else {
+			summary.Inserted++
+		}
+	}
+
+	logger.Info("Imported catalog", "inserted", summary.Inserted, "updated", summary.Updated, "skipped", summary.Skipped, "errors", len(summary.Errors), "overwrite", overwrite)
+	writeJSON(w, r, summary)
+}
+
+// importRepo stores an exported repository. SaveRepo writes the scraped fields and, unless
+// keepManifests is set, the live manifest; the curation state it doesn't know about is copied
+// afterwards. The preferred config a curator chose belongs to the manifest, so it is kept along
+// with it.
+func importRepo(repo types.RepoInfo, keepManifests bool) error {
+	if keepManifests {
+		// SaveRepo's proposed path leaves the live manifest alone, so write the stored proposed
+		// manifest back through it
+		if err := db.QueryRow(`SELECT COALESCE(proposed_manifest, '{}') FROM repositories WHERE full_name = $1`, repo.FullName).
+			Scan(&repo.ProposedManifest); err != nil {
+			return err
+		}
+		if _, err := utils.SaveRepo(db, repo, true); err != nil {
+			return err
+		}
+		_, err := db.Exec(`
+			UPDATE repositories
+			SET deprecated = $1, superseded_by = NULLIF($2, ''), platforms = $3
+			WHERE full_name = $4
+		`, repo.Deprecated, repo.SupersededBy, utils.NormalizePlatforms(repo.Platforms), repo.FullName)
+		return err
+	}
+
+	if _, err := utils.SaveRepo(db, repo, false); err != nil {
+		return err
+	}
+
+	var proposed sql.NullString
+	if manifest := strings.TrimSpace(repo.ProposedManifest); manifest != "" && manifest != "{}" {
+		if !json.Valid([]byte(manifest)) {
+			return fmt.Errorf("invalid proposed manifest")
+		}
+		proposed = sql.NullString{String: manifest, Valid: true}
+	}
+	_, err := db.Exec(`
+		UPDATE repositories
+		SET proposed_manifest = $1::jsonb, deprecated = $2, superseded_by = NULLIF($3, ''), platforms = $4,
+			preferred_key = NULLIF($5, '')
+		WHERE full_name = $6
+	`, proposed, repo.Deprecated, repo.SupersededBy, utils.NormalizePlatforms(repo.Platforms), strings.TrimSpace(repo.PreferredKey), repo.FullName)
+	return err
+}
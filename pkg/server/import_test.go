@@ -0,0 +1,129 @@
+//go:build cgo
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postImport posts body to the import endpoint and returns the summary it answers with.
+func postImport(t *testing.T, query, body string) importSummary {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/import"+query, strings.NewReader(body))
+	r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+	w := httptest.NewRecorder()
+	importCatalogHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api/import%s = %d %s", query, w.Code, w.Body.String())
+	}
+	var summary importSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestImportUpdatesScrapedFields(t *testing.T) {
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+
+	const (
+		curated  = `[{"command":"npx","args":["-y","curated"],"env":[],"preferred":true}]`
+		proposed = `[{"command":"uvx","args":["proposed"],"env":[]}]`
+		imported = `[{\"command\":\"npx\",\"args\":[\"-y\",\"imported\"],\"env\":[]}]`
+	)
+	body := `[{"fullName":"owner/repo","description":"new description","stars":42,"manifest":"` + imported + `"}]`
+
+	tests := []struct {
+		name         string
+		query        string
+		wantManifest string
+		wantProposed string
+	}{
+		{name: "manifests kept", query: "", wantManifest: "curated", wantProposed: "proposed"},
+		{name: "manifests overwritten", query: "?overwrite=true", wantManifest: "imported", wantProposed: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+			insertTestRepo(t, "owner/repo", map[string]any{
+				"description":       "old description",
+				"manifest":          curated,
+				"proposed_manifest": proposed,
+			})
+
+			summary := postImport(t, tt.query, body)
+			if summary.Updated != 1 || summary.Inserted != 0 || summary.Skipped != 0 {
+				t.Errorf("summary = %+v, want 1 updated", summary)
+			}
+
+			var description, manifest, proposedManifest string
+			var stars int
+			if err := db.QueryRow(`SELECT description, stars, manifest, COALESCE(proposed_manifest, '') FROM repositories WHERE full_name = 'owner/repo'`).
+				Scan(&description, &stars, &manifest, &proposedManifest); err != nil {
+				t.Fatal(err)
+			}
+			if description != "new description" || stars != 42 {
+				t.Errorf("description %q and stars %d were not updated", description, stars)
+			}
+			if !strings.Contains(manifest, tt.wantManifest) {
+				t.Errorf("manifest = %s, want the %s one", manifest, tt.wantManifest)
+			}
+			if (tt.wantProposed == "") != (proposedManifest == "") || !strings.Contains(proposedManifest, tt.wantProposed) {
+				t.Errorf("proposed_manifest = %q, want the %q one", proposedManifest, tt.wantProposed)
+			}
+		})
+	}
+}
+
+func TestImportReportsBadEntries(t *testing.T) {
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+	newTestDB(t)
+
+	// The second entry has a value of the wrong type and is skipped; the truncated fourth one
+	// stops the import without losing the entries before it
+	summary := postImport(t, "", `[{"fullName":"owner/one"},{"fullName":"owner/bad","stars":"many"},{"fullName":"owner/two"},{"fullName":`)
+	if summary.Inserted != 2 || summary.Skipped != 2 || len(summary.Errors) != 2 {
+		t.Errorf("summary = %+v, want 2 inserted and 2 errors", summary)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM repositories WHERE full_name IN ('owner/one', 'owner/two')`).Scan(&count); err != nil || count != 2 {
+		t.Errorf("found %d imported repositories (%v), want 2", count, err)
+	}
+}
+
+func TestExportImportKeepsChosenPreferred(t *testing.T) {
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+	newTestDB(t)
+
+	const chosen = "docker run -i owner/image"
+	insertTestRepo(t, "owner/repo", map[string]any{
+		"manifest":      `[{"command":"npx","args":["-y","server"],"env":[]},{"command":"docker","args":["run","-i","owner/image"],"env":[],"preferred":true}]`,
+		"preferred_key": chosen,
+	})
+	r := httptest.NewRequest(http.MethodGet, "/api/export", nil)
+	r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+	w := httptest.NewRecorder()
+	exportCatalogHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/export = %d %s", w.Code, w.Body.String())
+	}
+
+	// Restore the export into an empty catalog
+	newTestDB(t)
+	if summary := postImport(t, "", w.Body.String()); summary.Inserted != 1 {
+		t.Fatalf("summary = %+v, want 1 inserted", summary)
+	}
+	var key string
+	if err := db.QueryRow(`SELECT COALESCE(preferred_key, '') FROM repositories WHERE full_name = 'owner/repo'`).Scan(&key); err != nil {
+		t.Fatal(err)
+	}
+	if key != chosen {
+		t.Errorf("preferred_key = %q after the round trip, want %q", key, chosen)
+	}
+}
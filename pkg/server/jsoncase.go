@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// writeJSON writes v as the JSON response. With ?case=snake the field names of structs are
+// written in snake_case (fullName becomes full_name); the default camelCase is unchanged. Map keys
+// are data, such as server names or env var keys, and are never renamed.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jsonCase(r, v))
+}
+
+// jsonCase returns v in the field casing the request asked for.
+func jsonCase(r *http.Request, v any) any {
+	if r.URL.Query().Get("case") == "snake" {
+		return snakeCased(reflect.ValueOf(v))
+	}
+	return v
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// snakeCased converts v into a value that encodes like v would, but with the names of struct
+// fields in snake_case. It follows the json tags, including omitempty and "-".
+func snakeCased(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	// Types with their own encoding, such as time.Time, are kept as they are
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return snakeCased(v.Elem())
+	case reflect.Struct:
+		var object snakeObject
+		appendFields(&object, v)
+		return object
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m[fmt.Sprint(iter.Key().Interface())] = snakeCased(iter.Value())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = snakeCased(v.Index(i))
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+// appendFields adds the exported fields of the struct v to object, flattening embedded structs
+// the way encoding/json does.
+func appendFields(object *snakeObject, v reflect.Value) {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		value := v.Field(i)
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				appendFields(object, value)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && isEmptyValue(value) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		*object = append(*object, snakeField{key: toSnakeCase(name), value: snakeCased(value)})
+	}
+}
+
+// isEmptyValue reports whether v is empty in the sense of omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero() && v.Kind() != reflect.Struct
+	}
+}
+
+// toSnakeCase converts a camelCase name, e.g. "readmeSha" or "URLDescription", to snake_case.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at a lower to upper change, and at the last capital of an acronym
+			// that is followed by a lowercase letter
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type snakeField struct {
+	key   string
+	value any
+}
+
+// snakeObject is a JSON object that keeps the order of the struct fields it was built from.
+type snakeObject []snakeField
+
+func (o snakeObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
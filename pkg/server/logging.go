@@ -0,0 +1,36 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// initLogger configures the default slog logger. LOG_LEVEL sets the minimum level (debug, info,
+// warn or error; default info) and LOG_FORMAT=json switches to JSON output for log collectors.
+// Output from the standard log package is routed through the same handler.
+func initLogger() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "json") {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// requestLogger returns a logger that tags messages with the route handling r and the request id.
+func requestLogger(r *http.Request) *slog.Logger {
+	return slog.With("handler", r.Pattern, "requestId", requestIDFromContext(r.Context()))
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// registerDBMetrics exposes the connection pool stats of the primary and, if configured, the
+// read replica.
+func registerDBMetrics() {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "primary"))
+	if readDB != nil {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(readDB, "replica"))
+	}
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// metricsMiddleware records the duration of every request, labelled with the mux pattern that
+// served it so that path parameters don't create a series per repository.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// The mux sets the pattern on the request once it has routed it
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		utils.HTTPDuration.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	})
+}
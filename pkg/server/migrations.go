@@ -0,0 +1,88 @@
+package server
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// Schema changes live in migrations/<driver>/ as numbered SQL files. They run in file name order
+// and each one is recorded in schema_migrations so it only runs once. Add a new file for every
+// change, to both drivers, instead of editing one that has already shipped.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite3/*.sql
+var migrationFiles embed.FS
+
+func runSchemaMigrations() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	applied := make(map[string]bool)
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("error querying applied migrations: %v", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	dir := "migrations/" + dbDriver + "/"
+	names, err := fs.Glob(migrationFiles, dir+"*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, dir), ".sql")
+		if applied[version] {
+			continue
+		}
+		if err := applyMigration(name, version); err != nil {
+			return fmt.Errorf("error applying migration %s: %v", version, err)
+		}
+		slog.Info("Applied schema migration", "version", version)
+	}
+	return nil
+}
+
+// applyMigration runs one migration file and records it in the same transaction, so a failed
+// migration is retried on the next start.
+func applyMigration(name, version string) error {
+	content, err := migrationFiles.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(string(content)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT (version) DO NOTHING`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
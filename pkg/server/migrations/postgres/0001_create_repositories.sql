@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS repositories (
+	id SERIAL PRIMARY KEY,
+	path TEXT,
+	display_name TEXT,
+	full_name TEXT UNIQUE,
+	url TEXT,
+	description TEXT,
+	stars INTEGER,
+	readme_content TEXT,
+	language TEXT,
+	manifest JSONB,
+	proposed_manifest JSONB,
+	icon TEXT,
+	tool_definitions JSONB,
+	metadata JSONB,
+	license TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
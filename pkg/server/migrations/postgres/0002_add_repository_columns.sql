@@ -0,0 +1,9 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS proposed_manifest JSONB;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS license TEXT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS staging_manifest JSONB;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS analyzed_sha TEXT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS readme_sha TEXT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS owner_icon TEXT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS deprecated BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS superseded_by TEXT;
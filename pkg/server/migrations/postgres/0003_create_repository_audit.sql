@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS repository_audit (
+	id SERIAL PRIMARY KEY,
+	repo_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	old_value TEXT,
+	new_value TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS repository_audit_repo_id_idx ON repository_audit (repo_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS manifest_env (
+	repo_id INTEGER NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+	key TEXT NOT NULL,
+	name TEXT,
+	required BOOLEAN NOT NULL DEFAULT false,
+	sensitive BOOLEAN NOT NULL DEFAULT false,
+	PRIMARY KEY (repo_id, key)
+);
+CREATE INDEX IF NOT EXISTS manifest_env_key_idx ON manifest_env (key);
@@ -0,0 +1,15 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+UPDATE repositories SET updated_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE updated_at IS NULL;
+ALTER TABLE repositories ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+CREATE INDEX IF NOT EXISTS repositories_updated_at_idx ON repositories (updated_at);
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+	NEW.updated_at = CURRENT_TIMESTAMP;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS repositories_updated_at ON repositories;
+CREATE TRIGGER repositories_updated_at BEFORE UPDATE ON repositories
+	FOR EACH ROW EXECUTE FUNCTION set_updated_at();
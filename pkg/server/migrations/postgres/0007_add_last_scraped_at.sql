@@ -0,0 +1,2 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS last_scraped_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS repositories_last_scraped_at_idx ON repositories (last_scraped_at);
@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS platforms TEXT NOT NULL DEFAULT '';
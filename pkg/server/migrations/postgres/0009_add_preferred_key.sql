@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS preferred_key TEXT;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS scrape_pending (
+	repo TEXT NOT NULL,
+	path TEXT NOT NULL,
+	stars INTEGER NOT NULL DEFAULT 0,
+	pushed_at TIMESTAMP,
+	deferred_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (repo, path)
+);
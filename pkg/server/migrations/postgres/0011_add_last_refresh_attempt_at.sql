@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS last_refresh_attempt_at TIMESTAMP;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS repositories (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	path TEXT,
+	display_name TEXT,
+	full_name TEXT UNIQUE,
+	url TEXT,
+	description TEXT,
+	stars INTEGER,
+	readme_content TEXT,
+	language TEXT,
+	manifest TEXT,
+	proposed_manifest TEXT,
+	staging_manifest TEXT,
+	icon TEXT,
+	owner_icon TEXT,
+	tool_definitions TEXT,
+	metadata TEXT,
+	license TEXT,
+	version INTEGER NOT NULL DEFAULT 1,
+	analyzed_sha TEXT,
+	readme_sha TEXT,
+	deprecated BOOLEAN NOT NULL DEFAULT false,
+	superseded_by TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
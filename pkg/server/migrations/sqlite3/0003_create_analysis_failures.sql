@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS analysis_failures (
+	full_name TEXT PRIMARY KEY,
+	url TEXT,
+	analysis_attempts INTEGER NOT NULL DEFAULT 0,
+	last_analysis_error TEXT,
+	last_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
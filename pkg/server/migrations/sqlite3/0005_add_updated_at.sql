@@ -0,0 +1,15 @@
+ALTER TABLE repositories ADD COLUMN updated_at TIMESTAMP;
+UPDATE repositories SET updated_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+CREATE INDEX IF NOT EXISTS repositories_updated_at_idx ON repositories (updated_at);
+
+CREATE TRIGGER IF NOT EXISTS repositories_updated_at_insert AFTER INSERT ON repositories
+	FOR EACH ROW WHEN NEW.updated_at IS NULL
+BEGIN
+	UPDATE repositories SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS repositories_updated_at AFTER UPDATE ON repositories
+	FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+	UPDATE repositories SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN platforms TEXT NOT NULL DEFAULT '';
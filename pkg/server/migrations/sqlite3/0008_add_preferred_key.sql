@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN preferred_key TEXT;
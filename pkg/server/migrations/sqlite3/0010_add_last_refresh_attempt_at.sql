@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN last_refresh_attempt_at TIMESTAMP;
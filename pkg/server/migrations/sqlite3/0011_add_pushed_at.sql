@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN pushed_at TIMESTAMP;
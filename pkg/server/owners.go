@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+var ownersCache = &cache[[]types.OwnerStats]{ttl: 10 * time.Minute}
+
+// getOwnersHandler lists the publishers in the catalog with their number of servers, total stars
+// and the categories they cover, most prolific first.
+func getOwnersHandler(w http.ResponseWriter, r *http.Request) {
+	owners, err := ownersCache.get(countOwners)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error computing owners: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, owners, len(owners))
+}
+
+func countOwners() ([]types.OwnerStats, error) {
+	rows, err := reader().Query(`
+		SELECT split_part(full_name, '/', 1) AS owner,
+			COALESCE(MAX(owner_icon), ''),
+			COUNT(*),
+			COALESCE(SUM(stars), 0),
+			COALESCE(string_agg(metadata->>'categories', ','), '')
+		FROM repositories
+		WHERE NOT deprecated
+		GROUP BY owner
+		ORDER BY COUNT(*) DESC, SUM(stars) DESC, owner
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make([]types.OwnerStats, 0)
+	for rows.Next() {
+		var (
+			owner      types.OwnerStats
+			categories string
+		)
+		if err := rows.Scan(&owner.Owner, &owner.Icon, &owner.Servers, &owner.Stars, &categories); err != nil {
+			return nil, err
+		}
+		owner.Categories = []string{}
+		if categories = utils.NormalizeCategories(categories); categories != "" {
+			owner.Categories = strings.Split(categories, ",")
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+// getOwnerReposHandler lists the servers of one publisher, most starred first. The owner has to
+// match the first segment of full_name exactly, so "foo" does not match "foobar/server".
+func getOwnerReposHandler(w http.ResponseWriter, r *http.Request) {
+	owner := strings.ToLower(strings.TrimSpace(r.PathValue("owner")))
+	if owner == "" || strings.Contains(owner, "/") {
+		http.Error(w, fmt.Sprintf("Invalid owner %q", r.PathValue("owner")), http.StatusBadRequest)
+		return
+	}
+	limit, offset := parsePagination(r)
+
+	condition := "split_part(full_name, '/', 1) = $1"
+	if r.URL.Query().Get("includeDeprecated") != "true" {
+		condition += " AND NOT deprecated"
+	}
+
+	var totalCount int
+	if err := reader().QueryRow(`SELECT COUNT(*) FROM repositories WHERE `+condition, owner).Scan(&totalCount); err != nil {
+		http.Error(w, fmt.Sprintf("Error counting repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := reader().Query(`
+		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), COALESCE(owner_icon, ''), metadata, COALESCE(license, ''), version, deprecated, COALESCE(superseded_by, ''), platforms, last_scraped_at
+		FROM repositories
+		WHERE `+condition+`
+		ORDER BY stars DESC, full_name
+		LIMIT $2 OFFSET $3
+	`, owner, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	repos := make([]types.RepoInfo, 0)
+	for rows.Next() {
+		var repo types.RepoInfo
+		if err := rows.Scan(
+			&repo.ID,
+			&repo.Path,
+			&repo.FullName,
+			&repo.DisplayName,
+			&repo.URL,
+			&repo.Description,
+			&repo.Stars,
+			&repo.Language,
+			&repo.Manifest,
+			&repo.Icon,
+			&repo.OwnerIcon,
+			&repo.Metadata,
+			&repo.License,
+			&repo.Version,
+			&repo.Deprecated,
+			&repo.SupersededBy,
+			&repo.Platforms,
+			&repo.LastScrapedAt,
+		); err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+		utils.SetMonorepoFields(&repo)
+		repos = append(repos, repo)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, repos, totalCount)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// popularTopN returns how many of the most-starred repositories count as "Popular".
+func popularTopN() int {
+	n, _ := strconv.Atoi(os.Getenv("POPULAR_TOP_N"))
+	if n <= 0 {
+		n = 50
+	}
+	return n
+}
+
+// popularMinStars returns the minimum star count a repository needs to be "Popular".
+func popularMinStars() int {
+	n, _ := strconv.Atoi(os.Getenv("POPULAR_MIN_STARS"))
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// popularRepoIDs returns the IDs of the repositories that currently belong to the
+// computed "Popular" category, i.e. the top N repositories by stars above the minimum.
+func popularRepoIDs() (map[int]bool, error) {
+	rows, err := db.Query(`SELECT id FROM repositories WHERE stars >= $1 ORDER BY stars DESC LIMIT $2`, popularMinStars(), popularTopN())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// refreshComputedCategories recomputes membership-based flags and stores them in each
+// repository's metadata so they follow star counts instead of freezing at scrape time.
+func refreshComputedCategories() error {
+	popularIDs, err := popularRepoIDs()
+	if err != nil {
+		return fmt.Errorf("error computing popular repositories: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT id, COALESCE(metadata, '{}') FROM repositories`)
+	if err != nil {
+		return fmt.Errorf("error querying repositories: %v", err)
+	}
+
+	updates := make(map[int][]byte)
+	for rows.Next() {
+		var id int
+		var metadataRaw string
+		if err := rows.Scan(&id, &metadataRaw); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning repository: %v", err)
+		}
+
+		metadata := map[string]string{}
+		if err := json.Unmarshal([]byte(metadataRaw), &metadata); err != nil {
+			slog.Warn("Skipping repository with invalid metadata", "id", id, "error", err)
+			continue
+		}
+
+		wasPopular := metadata["Popular"] == "true"
+		if wasPopular == popularIDs[id] {
+			continue
+		}
+		if popularIDs[id] {
+			metadata["Popular"] = "true"
+		} else {
+			delete(metadata, "Popular")
+		}
+
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error marshaling metadata for repository %d: %v", id, err)
+		}
+		updates[id] = metadataBytes
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating repositories: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, metadata := range updates {
+		if _, err := tx.Exec(`UPDATE repositories SET metadata = $1::jsonb WHERE id = $2`, metadata, id); err != nil {
+			return fmt.Errorf("error updating metadata for repository %d: %v", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Info("Refreshed computed categories", "popular", len(popularIDs), "updated", len(updates))
+	return nil
+}
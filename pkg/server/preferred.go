@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// recomputePreferredHandler re-applies the preferred command order to every stored manifest
+// without calling OpenAI, e.g. after PREFERRED_COMMAND_ORDER changed. Configs a curator chose
+// stay preferred. With ?rescrapeTools=true the tool definitions of repositories whose preferred
+// config changed are scraped again in the background.
+func recomputePreferredHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	logger := requestLogger(r)
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	type storedManifest struct {
+		id           int
+		manifest     string
+		preferredKey string
+	}
+	rows, err := tx.Query(`
+		SELECT id, manifest::text, COALESCE(preferred_key, '')
+		FROM repositories
+		WHERE manifest IS NOT NULL AND manifest <> '{}'
+		FOR UPDATE
+	`)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying manifests: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var manifests []storedManifest
+	for rows.Next() {
+		var manifest storedManifest
+		if err := rows.Scan(&manifest.id, &manifest.manifest, &manifest.preferredKey); err != nil {
+			rows.Close()
+			http.Error(w, fmt.Sprintf("Error scanning manifest: %v", err), http.StatusInternalServerError)
+			return
+		}
+		manifests = append(manifests, manifest)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating manifests: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var changed []int
+	for _, manifest := range manifests {
+		configs, err := utils.ParseManifest(manifest.manifest)
+		if err != nil {
+			logger.Warn("Skipping repository with invalid manifest", "id", manifest.id, "error", err)
+			continue
+		}
+
+		before := make([]bool, len(configs))
+		for i := range configs {
+			before[i] = configs[i].Preferred
+		}
+		utils.MarkChosenPreferred(configs, manifest.preferredKey)
+
+		same := true
+		for i := range configs {
+			if configs[i].Preferred != before[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			continue
+		}
+
+		updated, err := json.Marshal(configs)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error marshaling manifest of repository %d: %v", manifest.id, err), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`
+			UPDATE repositories
+			SET manifest = $1::jsonb, version = version + 1
+			WHERE id = $2
+		`, string(updated), manifest.id); err != nil {
+			http.Error(w, fmt.Sprintf("Error updating manifest of repository %d: %v", manifest.id, err), http.StatusInternalServerError)
+			return
+		}
+		if err := recordAudit(tx, manifest.id, "recompute_preferred", manifest.manifest, string(updated)); err != nil {
+			http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+			return
+		}
+		changed = append(changed, manifest.id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error committing preferred configs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Recomputed preferred configs", "changed", len(changed), "checked", len(manifests))
+
+	rescrape := r.URL.Query().Get("rescrapeTools") == "true" && len(changed) > 0
+	if rescrape {
+		go rescrapeToolDefinitions(changed)
+	}
+
+	writeJSON(w, r, map[string]any{
+		"checked":        len(manifests),
+		"changed":        len(changed),
+		"toolsRescraped": rescrape,
+	})
+}
+
+// rescrapeToolDefinitions scrapes and stores the tool definitions of the given repositories again.
+func rescrapeToolDefinitions(repoIDs []int) {
+	ctx := context.Background()
+	for _, id := range repoIDs {
+		var repo types.RepoInfo
+		err := db.QueryRow(`SELECT full_name, COALESCE(path, ''), COALESCE(readme_content, '') FROM repositories WHERE id = $1`, id).
+			Scan(&repo.FullName, &repo.Path, &repo.ReadmeContent)
+		if err != nil {
+			slog.Error("Error loading repository for tool rescrape", "id", id, "error", err)
+			continue
+		}
+
+		if err := utils.ScrapeToolDefinitions(ctx, &repo, db, githubClient, openaiClient); err != nil {
+			slog.Error("Error scraping tool definitions", "repo", repo.FullName, "error", err)
+			continue
+		}
+		if _, err := db.Exec(`UPDATE repositories SET tool_definitions = $1::jsonb WHERE id = $2`, repo.ToolDefinitions, id); err != nil {
+			slog.Error("Error saving tool definitions", "repo", repo.FullName, "error", err)
+		}
+	}
+	slog.Info("Finished rescraping tool definitions", "count", len(repoIDs))
+}
+
+// setPreferredHandler lets a curator choose the preferred config of a repository, overriding the
+// command order: PUT with {"config": n} marks config n of the live manifest as preferred and keeps
+// it preferred through later analyses and recomputes, as long as the README still documents it.
+// DELETE hands the choice back to the command order. With ?rescrapeTools=true the tool
+// definitions are scraped again in the background when the preferred config changed.
+func setPreferredHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var index *int
+	if r.Method == http.MethodPut {
+		var input struct {
+			Config *int `json:"config"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Config == nil {
+			http.Error(w, `Invalid request body: expected {"config": <index>}`, http.StatusBadRequest)
+			return
+		}
+		index = input.Config
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var oldManifest string
+	err = tx.QueryRow(`SELECT COALESCE(manifest::text, '') FROM repositories WHERE id = $1 FOR UPDATE`, repoID).Scan(&oldManifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs, err := utils.ParseManifest(oldManifest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored manifest: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var preferredKey sql.NullString
+	if index != nil {
+		if *index < 0 || *index >= len(configs) {
+			http.Error(w, fmt.Sprintf("Invalid config %d: the manifest has %d configs", *index, len(configs)), http.StatusBadRequest)
+			return
+		}
+		preferredKey = sql.NullString{String: utils.ConfigKey(configs[*index]), Valid: true}
+	}
+
+	before := make([]bool, len(configs))
+	for i := range configs {
+		before[i] = configs[i].Preferred
+	}
+	utils.MarkChosenPreferred(configs, preferredKey.String)
+	changed := false
+	for i := range configs {
+		changed = changed || configs[i].Preferred != before[i]
+	}
+
+	newManifest := oldManifest
+	if changed {
+		updated, err := json.Marshal(configs)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error marshaling manifest: %v", err), http.StatusInternalServerError)
+			return
+		}
+		newManifest = string(updated)
+	}
+	if _, err := tx.Exec(`
+		UPDATE repositories
+		SET manifest = $1::jsonb, preferred_key = $2, version = version + 1
+		WHERE id = $3
+	`, newManifest, preferredKey, repoID); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	action := "set_preferred"
+	if index == nil {
+		action = "clear_preferred"
+	}
+	if err := recordAudit(tx, repoID, action, oldManifest, newManifest); err != nil {
+		http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	requestLogger(r).Info("Updated preferred config", "id", repoID, "action", action, "changed", changed)
+
+	rescrape := r.URL.Query().Get("rescrapeTools") == "true" && changed
+	if rescrape {
+		go rescrapeToolDefinitions([]int{repoID})
+	}
+
+	writeJSON(w, r, map[string]any{
+		"status":         "success",
+		"changed":        changed,
+		"locked":         preferredKey.Valid,
+		"toolsRescraped": rescrape,
+	})
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scrapeProgressInterval is how often a running scrape logs its progress, SCRAPE_PROGRESS_INTERVAL
+// (default 30s). Zero turns the periodic log off.
+var scrapeProgressInterval = sync.OnceValue(func() time.Duration {
+	value := os.Getenv("SCRAPE_PROGRESS_INTERVAL")
+	if value == "" {
+		return 30 * time.Second
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		slog.Warn("Invalid SCRAPE_PROGRESS_INTERVAL, using 30s", "interval", value)
+		return 30 * time.Second
+	}
+	return interval
+})
+
+// scrapeProgress counts what happened to the repositories handed to a worker pool. The counters
+// are updated by the workers concurrently.
+type scrapeProgress struct {
+	total int
+	start time.Time
+
+	added    atomic.Int64
+	skipped  atomic.Int64
+	failed   atomic.Int64
+	deferred atomic.Int64
+}
+
+func newScrapeProgress(total int) *scrapeProgress {
+	return &scrapeProgress{total: total, start: time.Now()}
+}
+
+func (p *scrapeProgress) done() int64 {
+	return p.added.Load() + p.skipped.Load() + p.failed.Load() + p.deferred.Load()
+}
+
+// report logs the progress every scrapeProgressInterval until stop is closed.
+func (p *scrapeProgress) report(stop <-chan struct{}) {
+	interval := scrapeProgressInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.log("Scrape progress")
+		case <-stop:
+			return
+		}
+	}
+}
+
+// log logs the counts so far with the estimated time remaining at the throughput so far.
+func (p *scrapeProgress) log(msg string) {
+	done := p.done()
+	elapsed := time.Since(p.start)
+	args := []any{
+		"processed", done,
+		"total", p.total,
+		"added", p.added.Load(),
+		"skipped", p.skipped.Load(),
+		"failed", p.failed.Load(),
+		"deferred", p.deferred.Load(),
+		"elapsed", elapsed.Round(time.Second),
+	}
+	if remaining := int64(p.total) - done; done > 0 && remaining > 0 {
+		eta := time.Duration(float64(elapsed) / float64(done) * float64(remaining))
+		args = append(args, "remaining", eta.Round(time.Second))
+	}
+	slog.Info(msg, args...)
+}
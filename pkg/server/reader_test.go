@@ -0,0 +1,63 @@
+//go:build cgo
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyHandlersUseReadDB(t *testing.T) {
+	newTestDB(t)
+	newTestReadDB(t)
+
+	// Everything lives on the replica only, so a handler that queries the primary doesn't find it
+	primary := db
+	db = readDB
+	id := insertTestRepo(t, "owner/replica-only", map[string]any{
+		"language":          "Zig",
+		"manifest":          `[{"command":"npx","args":["-y","replica-only"]}]`,
+		"staging_manifest":  `[{"command":"npx","args":["-y","replica-staging"]}]`,
+		"proposed_manifest": `[{"command":"npx","args":["-y","replica-proposed"]}]`,
+		"metadata":          `{"categories":"Databases"}`,
+	})
+	for _, statement := range []string{
+		`INSERT INTO repository_audit (repo_id, action, new_value) VALUES (` + strconv.Itoa(id) + `, 'replica-audit', '')`,
+		`INSERT INTO analysis_failures (full_name, url, analysis_attempts, last_analysis_error) VALUES ('owner/replica-failure', '', 1, 'failed')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db = primary
+
+	path := "/api/repos/" + strconv.Itoa(id)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		want    string
+	}{
+		{"manifest", getRepoManifestHandler, path + "/manifest", "replica-only"},
+		{"staging", getRepoStagingHandler, path + "/staging", "replica-staging"},
+		{"history", getRepoHistoryHandler, path + "/history", "replica-audit"},
+		{"languages", getLanguagesHandler, "/api/languages", "Zig"},
+		{"categories", getCategoriesHandler, "/api/categories", "Databases"},
+		{"pending", getPendingReposHandler, "/api/repos/pending", "replica-proposed"},
+		{"failed analysis", getFailedAnalysisHandler, "/api/repos/failed-analysis", "owner/replica-failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.SetPathValue("id", strconv.Itoa(id))
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("GET %s = %d %s, want %q from the read database", tt.target, w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
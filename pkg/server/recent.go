@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultRecentLimit = 20
+	maxRecentLimit     = 100
+)
+
+// getRecentReposHandler lists the most recently added repositories, newest first, for a "What's
+// new" section. ?limit= sets how many (default 20, at most 100). Deprecated repositories are left
+// out.
+func getRecentReposHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecentLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid limit %q: must be a positive integer", raw), http.StatusBadRequest)
+			return
+		}
+		limit = min(value, maxRecentLimit)
+	}
+
+	rows, err := reader().Query(`
+		SELECT `+repoSummaryColumns+`
+		FROM repositories
+		WHERE NOT deprecated
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying recent repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	repos, err := scanRepoSummaries(rows)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading recent repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, repos, len(repos))
+}
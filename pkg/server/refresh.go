@@ -0,0 +1,136 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// refreshReadmeHandler fetches the current README of a repository and re-extracts the env var
+// and header documentation from it, without regenerating anything else. It touches only:
+//   - readme_content and readme_sha
+//   - the name, description, required, sensitive and file fields of env vars and headers in the
+//     live manifest, adding keys the README newly documents
+//
+// Categories, display name, description, commands, args, proposed manifests and tool definitions
+// are left as they are.
+func refreshReadmeHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var fullName, path string
+	err := db.QueryRow(`SELECT full_name, COALESCE(path, '') FROM repositories WHERE id = $1`, repoID).Scan(&fullName, &path)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	parts := strings.SplitN(fullName, "/", 3)
+	if len(parts) < 2 {
+		http.Error(w, fmt.Sprintf("Invalid repository name %q", fullName), http.StatusInternalServerError)
+		return
+	}
+	if path == "" {
+		path = "README.md"
+	}
+
+	var fileContent *github.RepositoryContent
+	err = utils.GitHubLimiter.Do(r.Context(), func() (resp *github.Response, err error) {
+		fileContent, _, resp, err = githubClient.Repositories.GetContents(r.Context(), parts[0], parts[1], path, nil)
+		return resp, err
+	})
+	if isNotFound(err) {
+		http.Error(w, fmt.Sprintf("README %s not found in %s/%s", path, parts[0], parts[1]), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching README: %v", err), http.StatusBadGateway)
+		return
+	}
+	readme, err := fileContent.GetContent()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding README: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	analysis, err := utils.AnalyzeWithOpenAI(r.Context(), openaiClient, fullName, readme, "")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error analyzing README: %v", err), http.StatusBadGateway)
+		return
+	}
+	analyzed := utils.DedupeConfigs(utils.DropInstallCommands(analysis.Configs))
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// The manifest is read again under lock so that edits made during the analysis are kept
+	var oldManifest string
+	if err := tx.QueryRow(`SELECT COALESCE(manifest::text, '') FROM repositories WHERE id = $1 FOR UPDATE`, repoID).Scan(&oldManifest); err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs, err := utils.ParseManifest(oldManifest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored manifest: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	newManifest := oldManifest
+	if len(configs) > 0 {
+		refreshed, err := json.Marshal(utils.RefreshEnvDocs(configs, analyzed))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error marshaling manifest: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if current, _ := json.Marshal(configs); string(current) != string(refreshed) {
+			newManifest = string(refreshed)
+		}
+	}
+	envChanged := newManifest != oldManifest
+
+	if _, err := tx.Exec(`UPDATE repositories SET readme_content = $1, readme_sha = $2 WHERE id = $3`, readme, fileContent.GetSHA(), repoID); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating README: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if envChanged {
+		if _, err := tx.Exec(`UPDATE repositories SET manifest = $1::jsonb, version = version + 1 WHERE id = $2`, newManifest, repoID); err != nil {
+			http.Error(w, fmt.Sprintf("Error updating manifest: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := utils.SyncManifestEnv(tx, repoID, newManifest); err != nil {
+			http.Error(w, fmt.Sprintf("Error syncing env vars: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := recordAudit(tx, repoID, "refresh_readme", oldManifest, newManifest); err != nil {
+			http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error refreshing repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestLogger(r).Info("Refreshed README", "repo", fullName, "envChanged", envChanged)
+	writeJSON(w, r, map[string]any{
+		"status":     "success",
+		"envChanged": envChanged,
+	})
+}
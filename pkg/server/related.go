@@ -0,0 +1,120 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+const relatedLimit = 10
+
+// getRelatedReposHandler returns up to ten repositories similar to the given one. Embedding
+// similarity is used when available, otherwise repositories are ranked by the number of shared
+// categories (plus one for the same language), then by stars.
+func getRelatedReposHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		language, metadata string
+		hasEmbedding       bool
+	)
+	embeddingCheck := "false"
+	if utils.EmbeddingsEnabled {
+		embeddingCheck = "embedding IS NOT NULL"
+	}
+	err := reader().QueryRow(`
+		SELECT COALESCE(language, ''), COALESCE(metadata::text, '{}'), `+embeddingCheck+`
+		FROM repositories
+		WHERE id = $1
+	`, repoID).Scan(&language, &metadata, &hasEmbedding)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var rows *sql.Rows
+	if hasEmbedding {
+		rows, err = reader().Query(`
+			SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, '')
+			FROM repositories
+			WHERE id <> $1 AND embedding IS NOT NULL
+			ORDER BY embedding <=> (SELECT embedding FROM repositories WHERE id = $1), stars DESC
+			LIMIT $2
+		`, repoID, relatedLimit)
+	} else {
+		parsed := map[string]string{}
+		if err := json.Unmarshal([]byte(metadata), &parsed); err != nil {
+			http.Error(w, fmt.Sprintf("Error parsing metadata: %v", err), http.StatusInternalServerError)
+			return
+		}
+		// Only compare real categories, not curation flags such as Verified or Featured. Each shared
+		// category counts one, matched against the comma separated list with separators on both ends.
+		args := []any{repoID, language, relatedLimit}
+		overlap := "CASE WHEN $2 <> '' AND language ILIKE $2 THEN 1 ELSE 0 END"
+		for _, category := range strings.Split(utils.NormalizeCategories(parsed["categories"]), ",") {
+			if category == "" {
+				continue
+			}
+			args = append(args, category)
+			overlap += fmt.Sprintf(" + CASE WHEN ',' || COALESCE(metadata->>'categories', '') || ',' LIKE '%%,' || $%d::text || ',%%' THEN 1 ELSE 0 END", len(args))
+		}
+
+		rows, err = reader().Query(`
+			SELECT id, path, full_name, display_name, url, description, stars, language, manifest, icon
+			FROM (
+				SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, '') AS icon,
+					`+overlap+` AS overlap
+				FROM repositories
+				WHERE id <> $1
+			) candidates
+			WHERE overlap > 0
+			ORDER BY overlap DESC, stars DESC
+			LIMIT $3
+		`, args...)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying related repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	repos := make([]types.RepoInfo, 0)
+	for rows.Next() {
+		var repo types.RepoInfo
+		err := rows.Scan(
+			&repo.ID,
+			&repo.Path,
+			&repo.FullName,
+			&repo.DisplayName,
+			&repo.URL,
+			&repo.Description,
+			&repo.Stars,
+			&repo.Language,
+			&repo.Manifest,
+			&repo.Icon,
+		)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+		repos = append(repos, repo)
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, repos, len(repos))
+}
@@ -1,12 +1,14 @@
 package server
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -15,13 +17,54 @@ import (
 	"github.com/obot-platform/catalog-service/pkg/utils"
 )
 
-func getReposHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
+// writeList writes items as a JSON array along with the X-Total-Count header. The body is
+// encoded up front so that an encoding failure results in a clean 500 rather than a partial array.
+// Like writeJSON, it honors ?case=snake.
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T, total int) {
+	if items == nil {
+		items = []T{}
+	}
+
+	body, err := json.Marshal(jsonCase(r, items))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// writeJSONError writes {"status": status, "error": message} with the given status code, for
+// endpoints whose clients read every response as JSON. status is "error" unless the client has to
+// tell the outcome apart from other errors.
+func writeJSONError(w http.ResponseWriter, r *http.Request, code int, status, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    status,
+		"error":     message,
+		"requestId": requestIDFromContext(r.Context()),
+	})
+}
+
+// parseRepoID extracts the {id} path value and validates that it is an integer.
+// On failure it writes a 400 response and returns false.
+func parseRepoID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := r.PathValue("id")
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid repository id %q: must be an integer", raw), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// parsePagination reads the limit and offset query parameters, ignoring invalid values.
+func parsePagination(r *http.Request) (int, int) {
 	limit := 10000
 	offset := 0
-	sort := "stars"
-	order := "desc"
-	filter := r.URL.Query().Get("filter")
 
 	limitParam := r.URL.Query().Get("limit")
 	if limitParam != "" {
@@ -37,6 +80,52 @@ func getReposHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	return limit, offset
+}
+
+// repoSummaryColumns are the columns scanRepoSummaries reads: enough to list a repository without
+// its README, manifests or metadata.
+const repoSummaryColumns = `id, COALESCE(path, ''), full_name, COALESCE(display_name, ''), COALESCE(url, ''), COALESCE(description, ''),
+	COALESCE(stars, 0), COALESCE(language, ''), COALESCE(icon, ''), COALESCE(owner_icon, ''), version, deprecated,
+	platforms, last_scraped_at, created_at`
+
+// scanRepoSummaries reads rows selected with repoSummaryColumns.
+func scanRepoSummaries(rows *sql.Rows) ([]types.RepoInfo, error) {
+	repos := make([]types.RepoInfo, 0)
+	for rows.Next() {
+		var repo types.RepoInfo
+		if err := rows.Scan(
+			&repo.ID,
+			&repo.Path,
+			&repo.FullName,
+			&repo.DisplayName,
+			&repo.URL,
+			&repo.Description,
+			&repo.Stars,
+			&repo.Language,
+			&repo.Icon,
+			&repo.OwnerIcon,
+			&repo.Version,
+			&repo.Deprecated,
+			&repo.Platforms,
+			&repo.LastScrapedAt,
+			&repo.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		utils.SetMonorepoFields(&repo)
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+func getReposHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	limit, offset := parsePagination(r)
+	sort := "stars"
+	order := "desc"
+	filter := r.URL.Query().Get("filter")
+
 	sortParam := r.URL.Query().Get("sort")
 	if sortParam != "" {
 		// Validate sort parameter to prevent SQL injection
@@ -53,13 +142,37 @@ func getReposHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Build the query
 	query := `
-		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), readme_content, metadata
+		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), COALESCE(owner_icon, ''), readme_content, metadata, COALESCE(license, ''), version, deprecated, COALESCE(superseded_by, ''), platforms, last_scraped_at
 		FROM repositories
 	`
 	countQuery := `SELECT COUNT(*) FROM repositories`
 
 	var args []interface{}
+	var conditions []string
+
+	minStarsParam := r.URL.Query().Get("minStars")
+	if minStarsParam != "" {
+		if val, err := strconv.Atoi(minStarsParam); err == nil && val >= 0 {
+			args = append(args, val)
+			conditions = append(conditions, "stars >= $"+strconv.Itoa(len(args)))
+		}
+	}
+
+	languageParam := r.URL.Query().Get("language")
+	if languageParam != "" {
+		args = append(args, languageParam)
+		conditions = append(conditions, "language ILIKE $"+strconv.Itoa(len(args)))
+	}
+
+	// Deprecated servers are hidden unless explicitly requested
+	if r.URL.Query().Get("includeDeprecated") != "true" {
+		conditions = append(conditions, "NOT deprecated")
+	}
+
 	var whereClause string
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
 
 	// Add the where clause to both queries
 	if whereClause != "" {
@@ -80,14 +193,14 @@ func getReposHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get total count for pagination
 	var totalCount int
-	err := db.QueryRow(countQuery, args[:len(args)-2]...).Scan(&totalCount)
+	err := reader().QueryRow(countQuery, args[:len(args)-2]...).Scan(&totalCount)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error counting repositories: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Query repositories from the database with limit and offset
-	rows, err := db.Query(query, args...)
+	rows, err := reader().Query(query, args...)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error querying repositories: %v", err), http.StatusInternalServerError)
 		return
@@ -96,6 +209,15 @@ func getReposHandler(w http.ResponseWriter, r *http.Request) {
 
 	overrideTotalCount := false
 
+	var popularIDs map[int]bool
+	if filter == "Popular" {
+		popularIDs, err = popularRepoIDs()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error computing popular repositories: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Parse the results
 	repos := make([]types.RepoInfo, 0)
 	for rows.Next() {
@@ -111,25 +233,38 @@ func getReposHandler(w http.ResponseWriter, r *http.Request) {
 			&repo.Language,
 			&repo.Manifest,
 			&repo.Icon,
+			&repo.OwnerIcon,
 			&repo.ReadmeContent,
 			&repo.Metadata,
+			&repo.License,
+			&repo.Version,
+			&repo.Deprecated,
+			&repo.SupersededBy,
+			&repo.Platforms,
+			&repo.LastScrapedAt,
 		)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error scanning repository: %v", err), http.StatusInternalServerError)
 			return
 		}
+		utils.SetMonorepoFields(&repo)
 
 		if filter != "" && filter != "all" {
 			var metadata map[string]string
 			err = json.Unmarshal([]byte(repo.Metadata), &metadata)
 			if err != nil {
 				http.Error(w, fmt.Sprintf("Error unmarshalling metadata for repository %s: %v", repo.FullName, err), http.StatusInternalServerError)
+				return
 			}
 
 			if filter == "Featured" {
 				if metadata["Featured"] == "true" {
 					repos = append(repos, repo)
 				}
+			} else if filter == "Popular" {
+				if popularIDs[repo.ID] {
+					repos = append(repos, repo)
+				}
 			} else if filter == "Verified" {
 				categories := metadata["categories"]
 				parts := strings.Split(categories, ",")
@@ -156,11 +291,9 @@ func getReposHandler(w http.ResponseWriter, r *http.Request) {
 	if overrideTotalCount {
 		totalCount = len(repos)
 	}
-	w.Header().Set("X-Total-Count", strconv.Itoa(totalCount))
 
 	// Return the repositories as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(repos)
+	writeList(w, r, repos, totalCount)
 }
 
 func searchReposHandler(w http.ResponseWriter, r *http.Request) {
@@ -175,7 +308,7 @@ func searchReposHandler(w http.ResponseWriter, r *http.Request) {
 	searchQuery := "%" + query + "%"
 
 	// Query repositories from the database that match the search query
-	rows, err := db.Query(`
+	rows, err := reader().Query(`
 		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), readme_content
 		FROM repositories
 		WHERE 
@@ -220,8 +353,7 @@ func searchReposHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return the repositories as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(repos)
+	writeList(w, r, repos, len(repos))
 }
 
 func searchReposByReadmeHandler(w http.ResponseWriter, r *http.Request) {
@@ -235,13 +367,17 @@ func searchReposByReadmeHandler(w http.ResponseWriter, r *http.Request) {
 	// Prepare the search query for SQL
 	searchQuery := "%" + query + "%"
 
+	// The readme itself is only returned on request since it makes broad searches enormous
+	includeReadme := r.URL.Query().Get("includeReadme") == "true"
+
 	// Query repositories from the database that match the search query in readme content
-	rows, err := db.Query(`
-		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), readme_content
+	rows, err := reader().Query(`
+		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''),
+			CASE WHEN $2 THEN readme_content ELSE '' END
 		FROM repositories
 		WHERE readme_content ILIKE $1
 		ORDER BY stars DESC
-	`, searchQuery)
+	`, searchQuery, includeReadme)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error searching repositories by readme: %v", err), http.StatusInternalServerError)
 		return
@@ -279,10 +415,12 @@ func searchReposByReadmeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return the repositories as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(repos)
+	writeList(w, r, repos, len(repos))
 }
 
+// generateConfigForSpecificRepoHandler re-analyzes a stored repository; ?target=staging writes only
+// its staging manifest. A missing repository is answered with 404 and a README without an MCP
+// server config with 422 and the status "no_mcp_server", both as JSON errors.
 func generateConfigForSpecificRepoHandler(w http.ResponseWriter, r *http.Request) {
 	if !utils.IsAuthorized(r) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -291,7 +429,10 @@ func generateConfigForSpecificRepoHandler(w http.ResponseWriter, r *http.Request
 
 	force := r.URL.Query().Get("force") == "true"
 
-	repoID := r.PathValue("id")
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
 
 	// Check if repository exists and get its data
 	var exists bool
@@ -313,7 +454,8 @@ func generateConfigForSpecificRepoHandler(w http.ResponseWriter, r *http.Request
 		COALESCE(path, ''),
 		COALESCE(proposed_manifest::text, '{}'),
 		COALESCE(tool_definitions::text, '{}'),
-		COALESCE(icon, '')
+		COALESCE(icon, ''),
+		COALESCE(license, '')
 		FROM repositories WHERE id = $1
 	`, repoID).Scan(
 		&exists,
@@ -330,6 +472,7 @@ func generateConfigForSpecificRepoHandler(w http.ResponseWriter, r *http.Request
 		&repo.ProposedManifest,
 		&repo.ToolDefinitions,
 		&repo.Icon,
+		&repo.License,
 	)
 	if err != nil && err != sql.ErrNoRows {
 		http.Error(w, fmt.Sprintf("Error checking repository existence: %v", err), http.StatusInternalServerError)
@@ -337,6 +480,7 @@ func generateConfigForSpecificRepoHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	if !exists {
+		writeJSONError(w, r, http.StatusNotFound, "error", "Repository not found")
 		return
 	}
 
@@ -347,14 +491,24 @@ func generateConfigForSpecificRepoHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if _, err := utils.UpdateRepo(r.Context(), repo, force, openaiClient, repo.FullName, readme, db, githubClient); err != nil {
-		http.Error(w, fmt.Sprintf("Error updating repository: %v", err), http.StatusInternalServerError)
+	// Staging generation writes only to staging_manifest, leaving the live and proposed manifests untouched
+	if r.URL.Query().Get("target") == "staging" {
+		err = utils.GenerateStagingManifest(r.Context(), repo, openaiClient, readme, db)
+	} else {
+		_, err = utils.UpdateRepo(r.Context(), repo, force, openaiClient, repo.FullName, readme, db, githubClient)
+	}
+	// Finding nothing is a legitimate outcome of the analysis, distinct from a failure. It used to
+	// be answered with 404 like a missing repository; 422 keeps the two apart.
+	if errors.Is(err, utils.ErrNoMCPServer) {
+		writeJSONError(w, r, http.StatusUnprocessableEntity, "no_mcp_server", "The README does not describe an MCP server config")
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating config: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, r, map[string]interface{}{
 		"status":  "success",
 		"message": "Repository processed successfully",
 	})
@@ -378,28 +532,30 @@ func getReposCountHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Execute the count query
 	var count int
-	err := db.QueryRow(query, args...).Scan(&count)
+	err := reader().QueryRow(query, args...).Scan(&count)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error counting repositories: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Return the count as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"count": count})
+	writeJSON(w, r, map[string]int{"count": count})
 }
 
 func getRepoHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from path
-	repoID := r.PathValue("id")
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
 
 	// Query the database
 	query := `
-			SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), readme_content, COALESCE(tool_definitions, '{}'), COALESCE(metadata, '{}'), COALESCE(proposed_manifest, '{}')
+			SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, ''), COALESCE(owner_icon, ''), readme_content, COALESCE(tool_definitions, '{}'), COALESCE(metadata, '{}'), COALESCE(proposed_manifest, '{}'), COALESCE(license, ''), COALESCE(staging_manifest, '{}'), version, COALESCE(analyzed_sha, ''), deprecated, COALESCE(superseded_by, ''), platforms, last_scraped_at, COALESCE(preferred_key, '')
 			FROM repositories 
 			WHERE id = $1
 		`
-	row := db.QueryRow(query, repoID)
+	row := reader().QueryRow(query, repoID)
 
 	var repo types.RepoInfo
 	err := row.Scan(
@@ -413,10 +569,20 @@ func getRepoHandler(w http.ResponseWriter, r *http.Request) {
 		&repo.Language,
 		&repo.Manifest,
 		&repo.Icon,
+		&repo.OwnerIcon,
 		&repo.ReadmeContent,
 		&repo.ToolDefinitions,
 		&repo.Metadata,
 		&repo.ProposedManifest,
+		&repo.License,
+		&repo.StagingManifest,
+		&repo.Version,
+		&repo.AnalyzedSHA,
+		&repo.Deprecated,
+		&repo.SupersededBy,
+		&repo.Platforms,
+		&repo.LastScrapedAt,
+		&repo.PreferredKey,
 	)
 
 	if err == sql.ErrNoRows {
@@ -427,9 +593,14 @@ func getRepoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if configs, err := utils.ParseManifest(repo.Manifest); err == nil {
+		repo.Requirements = utils.Requirements(configs)
+	}
+	utils.SetMonorepoFields(&repo)
+
 	// Return the repository as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(repo)
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(repo.Version)))
+	writeJSON(w, r, repo)
 }
 
 func updateRepoHandler(w http.ResponseWriter, r *http.Request) {
@@ -438,25 +609,113 @@ func updateRepoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	repoID := r.PathValue("id")
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
 
-	updatedManifest, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// The body is either the manifest itself or {"version": n, "manifest": [...]}
+	var (
+		configs []types.MCPServerConfig
+		version *int
+	)
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		var input struct {
+			Version  *int                    `json:"version"`
+			Manifest []types.MCPServerConfig `json:"manifest"`
+		}
+		if err := json.Unmarshal(body, &input); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid manifest: %v", err), http.StatusBadRequest)
+			return
+		}
+		configs, version = input.Manifest, input.Version
+	} else if err := json.Unmarshal(body, &configs); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid manifest: %v", err), http.StatusBadRequest)
+		return
+	}
+	if ifMatch := strings.Trim(r.Header.Get("If-Match"), `"`); ifMatch != "" {
+		val, err := strconv.Atoi(ifMatch)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid If-Match version %q", ifMatch), http.StatusBadRequest)
+			return
+		}
+		version = &val
+	}
+
+	if configs == nil {
+		configs = []types.MCPServerConfig{}
+	}
+	for i := range configs {
+		configs[i].Args = utils.NormalizeNpxArgs(configs[i].Command, configs[i].Args)
+	}
+	if err := utils.ValidateManifest(configs); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid manifest: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Re-marshal so only the known manifest fields are stored
+	updatedManifest, err := json.Marshal(configs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error marshaling manifest: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		oldManifest    string
+		currentVersion int
+	)
+	err = tx.QueryRow(`SELECT COALESCE(manifest::text, ''), version FROM repositories WHERE id = $1 FOR UPDATE`, repoID).Scan(&oldManifest, &currentVersion)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Without a version the update is unconditional; with one, it only applies if nobody else saved in between
+	if version != nil && *version != currentVersion {
+		http.Error(w, fmt.Sprintf("Repository was modified by someone else: current version is %d", currentVersion), http.StatusConflict)
+		return
+	}
+
 	query := `
 		UPDATE repositories
-		SET manifest = $1::jsonb
+		SET manifest = $1::jsonb, version = version + 1
 		WHERE id = $2
 	`
-	_, err = db.Exec(query, updatedManifest, repoID)
-	if err != nil {
+	if _, err := tx.Exec(query, updatedManifest, repoID); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := utils.SyncManifestEnv(tx, repoID, string(updatedManifest)); err != nil {
+		http.Error(w, fmt.Sprintf("Error syncing env vars: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := recordAudit(tx, repoID, "update_manifest", oldManifest, string(updatedManifest)); err != nil {
+		http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
 		http.Error(w, fmt.Sprintf("Error updating repository: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	newVersion := currentVersion + 1
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(newVersion)))
 	w.WriteHeader(200)
 }
 
@@ -466,7 +725,10 @@ func updateRepoMetadataHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	repoID := r.PathValue("id")
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
 
 	updatedMetadata, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -474,16 +736,41 @@ func updateRepoMetadataHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var oldMetadata string
+	err = tx.QueryRow(`SELECT COALESCE(metadata::text, '') FROM repositories WHERE id = $1 FOR UPDATE`, repoID).Scan(&oldMetadata)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	query := `
 		UPDATE repositories
 		SET metadata = $1::jsonb
 		WHERE id = $2
 	`
-	_, err = db.Exec(query, updatedMetadata, repoID)
+	_, err = tx.Exec(query, updatedMetadata, repoID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error updating repository metadata: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if err := recordAudit(tx, repoID, "update_metadata", oldMetadata, string(updatedMetadata)); err != nil {
+		http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error updating repository metadata: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(200)
 }
@@ -497,7 +784,7 @@ func rescrapeHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("force")
 	force := query == "true"
 
-	go collectData(force)
+	go collectData(shutdownCtx, force)
 
 	w.WriteHeader(200)
 }
@@ -518,15 +805,12 @@ func addRepoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parts := strings.Split(input.FullName, "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	owner, repo, err := parseRepoFullName(input.FullName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	owner := parts[1]
-	repo := parts[2]
-
 	query := "mcpServers filename:README.md repo:" + owner + "/" + repo
 	opts := &github.SearchOptions{
 		ListOptions: github.ListOptions{
@@ -534,18 +818,33 @@ func addRepoHandler(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	result, _, err := githubClient.Search.Code(r.Context(), query, opts)
+	var result *github.CodeSearchResult
+	err = utils.GitHubSearchLimiter.Do(r.Context(), func() (resp *github.Response, err error) {
+		result, resp, err = githubClient.Search.Code(r.Context(), query, opts)
+		return resp, err
+	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error searching repositories: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Code search misses repos whose config isn't in a README it has indexed, so fall back to fetching the README directly
+	if len(result.CodeResults) == 0 {
+		requestLogger(r).Info("No code search results, fetching README directly", "repo", owner+"/"+repo)
+		if _, err := addRepoFromReadme(r.Context(), owner, repo); err != nil {
+			http.Error(w, fmt.Sprintf("Error adding repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(200)
+		return
+	}
+
 	var errs []error
 	for _, codeResult := range result.CodeResults {
 		owner := *codeResult.Repository.Owner.Login
 		repoName := *codeResult.Repository.Name
 		path := codeResult.GetPath()
-		log.Printf("Processing repository: %s/%s/%s", owner, repoName, path)
+		requestLogger(r).Info("Processing repository", "repo", owner+"/"+repoName, "path", path)
 		_, err := AddRepo(r.Context(), owner, repoName, path, false)
 		if err != nil {
 			errs = append(errs, err)
@@ -560,25 +859,633 @@ func addRepoHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 }
 
+// parseRepoFullName accepts either owner/repo or a GitHub URL such as
+// https://github.com/owner/repo/tree/main/sub and returns the owner and repo.
+func parseRepoFullName(input string) (string, string, error) {
+	name := strings.TrimSpace(input)
+	name = strings.TrimPrefix(name, "https://")
+	name = strings.TrimPrefix(name, "http://")
+	name = strings.TrimPrefix(name, "www.")
+	name = strings.TrimPrefix(name, "github.com/")
+	name = strings.Trim(name, "/")
+
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[0], ".") {
+		return "", "", fmt.Errorf("invalid repository %q: expected owner/repo or https://github.com/owner/repo", input)
+	}
+
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), nil
+}
+
 func approveRepoHandler(w http.ResponseWriter, r *http.Request) {
 	if !utils.IsAuthorized(r) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	repoID := r.PathValue("id")
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var oldManifest, proposedManifest string
+	err = tx.QueryRow(`
+		SELECT COALESCE(manifest::text, ''), COALESCE(proposed_manifest::text, '')
+		FROM repositories WHERE id = $1 FOR UPDATE
+	`, repoID).Scan(&oldManifest, &proposedManifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	query := `
 		UPDATE repositories
 		SET manifest = proposed_manifest,
-    		proposed_manifest = NULL
+    		proposed_manifest = NULL,
+			version = version + 1
 		WHERE id = $1
 	`
-	_, err := db.Exec(query, repoID)
+	_, err = tx.Exec(query, repoID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error approving repository: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if err := utils.SyncManifestEnv(tx, repoID, proposedManifest); err != nil {
+		http.Error(w, fmt.Sprintf("Error syncing env vars: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := recordAudit(tx, repoID, "approve", oldManifest, proposedManifest); err != nil {
+		http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error approving repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(200)
+}
+
+func getRepoManifestHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var manifest string
+	err := reader().QueryRow(`SELECT COALESCE(manifest::text, '') FROM repositories WHERE id = $1`, repoID).Scan(&manifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching manifest: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Raw mode returns exactly what is stored
+	if r.URL.Query().Get("normalized") != "true" {
+		if manifest == "" {
+			manifest = "null"
+		}
+		w.Write([]byte(manifest))
+		return
+	}
+
+	configs, err := utils.ParseManifest(manifest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored manifest: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	normalized := utils.NormalizeManifest(configs)
+	if err := utils.ValidateManifest(normalized); err != nil {
+		http.Error(w, fmt.Sprintf("Stored manifest is invalid: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	json.NewEncoder(w).Encode(normalized)
+}
+
+type categorizeResult struct {
+	ID         int    `json:"id"`
+	Status     string `json:"status"`
+	Categories string `json:"categories,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func categorizeReposHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		IDs    []int    `json:"ids"`
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(input.IDs) == 0 {
+		http.Error(w, "At least one id is required", http.StatusBadRequest)
+		return
+	}
+	if len(input.Add) == 0 && len(input.Remove) == 0 {
+		http.Error(w, "At least one category to add or remove is required", http.StatusBadRequest)
+		return
+	}
+	for i := range input.Add {
+		input.Add[i] = strings.TrimSpace(input.Add[i])
+	}
+	for i := range input.Remove {
+		input.Remove[i] = strings.TrimSpace(input.Remove[i])
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	results := make([]categorizeResult, 0, len(input.IDs))
+	for _, id := range input.IDs {
+		var metadataRaw string
+		err := tx.QueryRow(`SELECT COALESCE(metadata, '{}') FROM repositories WHERE id = $1 FOR UPDATE`, id).Scan(&metadataRaw)
+		if err == sql.ErrNoRows {
+			results = append(results, categorizeResult{ID: id, Status: "not_found"})
+			continue
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching repository %d: %v", id, err), http.StatusInternalServerError)
+			return
+		}
+
+		metadata := map[string]string{}
+		if err := json.Unmarshal([]byte(metadataRaw), &metadata); err != nil {
+			results = append(results, categorizeResult{ID: id, Status: "error", Error: fmt.Sprintf("invalid metadata: %v", err)})
+			continue
+		}
+
+		oldCategories := metadata["categories"]
+		oldAICategories, tracked := metadata[utils.AICategoriesKey]
+		// Categories touched by a human are no longer the analyzer's to change
+		metadata[utils.AICategoriesKey] = utils.EditCategories(strings.Join(utils.AnalyzerCategories(metadata), ","), nil, append(input.Add, input.Remove...))
+		metadata["categories"] = utils.EditCategories(oldCategories, input.Add, input.Remove)
+		if tracked && metadata["categories"] == oldCategories && metadata[utils.AICategoriesKey] == oldAICategories {
+			results = append(results, categorizeResult{ID: id, Status: "unchanged", Categories: oldCategories})
+			continue
+		}
+
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error marshaling metadata for repository %d: %v", id, err), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`UPDATE repositories SET metadata = $1::jsonb WHERE id = $2`, metadataBytes, id); err != nil {
+			http.Error(w, fmt.Sprintf("Error updating repository %d: %v", id, err), http.StatusInternalServerError)
+			return
+		}
+		if err := recordAudit(tx, id, "categorize", metadataRaw, string(metadataBytes)); err != nil {
+			http.Error(w, fmt.Sprintf("Error recording audit entry for repository %d: %v", id, err), http.StatusInternalServerError)
+			return
+		}
+
+		requestLogger(r).Info("Updated categories", "id", id, "old", oldCategories, "new", metadata["categories"])
+		results = append(results, categorizeResult{ID: id, Status: "updated", Categories: metadata["categories"]})
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error committing categories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, results)
+}
+
+func getRepoStagingHandler(w http.ResponseWriter, r *http.Request) {
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var staging string
+	err := reader().QueryRow(`SELECT COALESCE(staging_manifest::text, '{}') FROM repositories WHERE id = $1`, repoID).Scan(&staging)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching staging manifest: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(staging))
+}
+
+func promoteStagingHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE repositories
+		SET proposed_manifest = staging_manifest,
+			staging_manifest = NULL
+		WHERE id = $1 AND staging_manifest IS NOT NULL AND staging_manifest::text <> '{}'
+	`, repoID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error promoting staging manifest: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM repositories WHERE id = $1)`, repoID).Scan(&exists); err != nil {
+			http.Error(w, fmt.Sprintf("Error checking repository existence: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Repository not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Repository has no staging manifest to promote", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(200)
+}
+
+func getLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := reader().Query(`
+		SELECT DISTINCT language
+		FROM repositories
+		WHERE language IS NOT NULL AND language <> ''
+		ORDER BY language
+	`)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying languages: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	languages := make([]string, 0)
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning language: %v", err), http.StatusInternalServerError)
+			return
+		}
+		languages = append(languages, language)
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating languages: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, languages, len(languages))
+}
+
+type categoryCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := reader().Query(`SELECT COALESCE(metadata, '{}') FROM repositories`)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var metadataRaw string
+		if err := rows.Scan(&metadataRaw); err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		var metadata map[string]string
+		if err := json.Unmarshal([]byte(metadataRaw), &metadata); err != nil {
+			continue
+		}
+		for _, category := range strings.Split(metadata["categories"], ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				counts[category]++
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Popular is computed from star counts rather than stored in metadata
+	popularIDs, err := popularRepoIDs()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error computing popular repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	counts["Popular"] = len(popularIDs)
+
+	categories := make([]categoryCount, 0, len(counts))
+	for name, count := range counts {
+		categories = append(categories, categoryCount{Name: name, Count: count})
+	}
+	slices.SortFunc(categories, func(a, b categoryCount) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	writeList(w, r, categories, len(categories))
+}
+
+func refreshIconsHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	missingOnly := r.URL.Query().Get("missingOnly") == "true"
+
+	filter := ""
+	if missingOnly {
+		filter = " AND COALESCE(icon, '') = ''"
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT split_part(full_name, '/', 1) FROM repositories WHERE full_name IS NOT NULL` + filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying repository owners: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var owners []string
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			rows.Close()
+			http.Error(w, fmt.Sprintf("Error scanning repository owner: %v", err), http.StatusInternalServerError)
+			return
+		}
+		owners = append(owners, owner)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repository owners: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Avatars belong to the owner, so one lookup per owner covers all of their repositories
+	var updated int64
+	errs := make([]string, 0)
+	for _, owner := range owners {
+		var user *github.User
+		err := utils.GitHubLimiter.Do(r.Context(), func() (resp *github.Response, err error) {
+			user, resp, err = githubClient.Users.Get(r.Context(), owner)
+			return resp, err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("error fetching owner %s: %v", owner, err))
+			continue
+		}
+
+		// Repository specific icons from the README are kept; only icons that were the owner
+		// avatar (or that predate owner_icon) follow the new avatar
+		result, err := db.Exec(`
+			UPDATE repositories
+			SET icon = CASE WHEN COALESCE(icon, '') = '' OR owner_icon IS NULL OR icon = owner_icon THEN $1 ELSE icon END,
+				owner_icon = $1
+			WHERE split_part(full_name, '/', 1) = $2 AND (owner_icon IS DISTINCT FROM $1 OR COALESCE(icon, '') = '')`+filter,
+			user.GetAvatarURL(), owner)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("error updating icons for owner %s: %v", owner, err))
+			continue
+		}
+		affected, _ := result.RowsAffected()
+		updated += affected
+	}
+
+	requestLogger(r).Info("Refreshed icons", "owners", len(owners), "updated", updated)
+
+	writeJSON(w, r, map[string]interface{}{
+		"owners":  len(owners),
+		"updated": updated,
+		"errors":  errs,
+	})
+}
+
+// pendingApprovalCondition matches repositories with a proposed manifest awaiting review.
+const pendingApprovalCondition = `proposed_manifest IS NOT NULL AND proposed_manifest <> '{}'`
+
+func approveAllReposHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Dry run only reports how many repositories are pending
+	if r.URL.Query().Get("count") == "true" {
+		var count int
+		if err := reader().QueryRow(`SELECT COUNT(*) FROM repositories WHERE ` + pendingApprovalCondition).Scan(&count); err != nil {
+			http.Error(w, fmt.Sprintf("Error counting pending repositories: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, r, map[string]int{"pending": count})
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	type pendingRepo struct {
+		id       int
+		manifest string
+		proposed string
+	}
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(manifest::text, ''), proposed_manifest::text
+		FROM repositories
+		WHERE ` + pendingApprovalCondition + `
+		FOR UPDATE
+	`)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying pending repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var pending []pendingRepo
+	for rows.Next() {
+		var repo pendingRepo
+		if err := rows.Scan(&repo.id, &repo.manifest, &repo.proposed); err != nil {
+			rows.Close()
+			http.Error(w, fmt.Sprintf("Error scanning pending repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pending = append(pending, repo)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating pending repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := tx.Exec(`
+		UPDATE repositories
+		SET manifest = proposed_manifest,
+			proposed_manifest = NULL,
+			version = version + 1
+		WHERE ` + pendingApprovalCondition)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error approving repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, repo := range pending {
+		if err := utils.SyncManifestEnv(tx, repo.id, repo.proposed); err != nil {
+			http.Error(w, fmt.Sprintf("Error syncing env vars for repository %d: %v", repo.id, err), http.StatusInternalServerError)
+			return
+		}
+		if err := recordAudit(tx, repo.id, "approve", repo.manifest, repo.proposed); err != nil {
+			http.Error(w, fmt.Sprintf("Error recording audit entry for repository %d: %v", repo.id, err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error approving repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	approved, _ := result.RowsAffected()
+	requestLogger(r).Info("Approved proposed manifests", "approved", approved)
+
+	writeJSON(w, r, map[string]int64{"approved": approved})
+}
+
+func getPendingReposHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+
+	var totalCount int
+	if err := reader().QueryRow(`SELECT COUNT(*) FROM repositories WHERE ` + pendingApprovalCondition).Scan(&totalCount); err != nil {
+		http.Error(w, fmt.Sprintf("Error counting pending repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := reader().Query(`
+		SELECT id, path, full_name, display_name, url, description, stars, language, COALESCE(manifest::text, '{}'), COALESCE(icon, ''), proposed_manifest::text, version
+		FROM repositories
+		WHERE `+pendingApprovalCondition+`
+		ORDER BY id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying pending repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	repos := make([]types.RepoInfo, 0)
+	for rows.Next() {
+		var repo types.RepoInfo
+		err := rows.Scan(
+			&repo.ID,
+			&repo.Path,
+			&repo.FullName,
+			&repo.DisplayName,
+			&repo.URL,
+			&repo.Description,
+			&repo.Stars,
+			&repo.Language,
+			&repo.Manifest,
+			&repo.Icon,
+			&repo.ProposedManifest,
+			&repo.Version,
+		)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+		repos = append(repos, repo)
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, repos, totalCount)
+}
+
+func rejectRepoHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var pending bool
+	var proposedManifest string
+	err = tx.QueryRow(`
+		SELECT `+pendingApprovalCondition+`, COALESCE(proposed_manifest::text, '')
+		FROM repositories WHERE id = $1 FOR UPDATE
+	`, repoID).Scan(&pending, &proposedManifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !pending {
+		http.Error(w, "Repository has no proposed manifest to reject", http.StatusConflict)
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE repositories SET proposed_manifest = NULL WHERE id = $1`, repoID); err != nil {
+		http.Error(w, fmt.Sprintf("Error rejecting repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := recordAudit(tx, repoID, "reject", proposedManifest, ""); err != nil {
+		http.Error(w, fmt.Sprintf("Error recording audit entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Error rejecting repository: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(200)
 }
@@ -0,0 +1,178 @@
+//go:build cgo
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+func TestRepoRoutesRejectNonNumericID(t *testing.T) {
+	newTestDB(t)
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	routes := []string{
+		"GET /api/repos/{id}",
+		"GET /api/repos/{id}/manifest",
+		"GET /api/repos/{id}/history",
+		"GET /api/repos/{id}/related",
+		"GET /api/repos/{id}/export",
+		"GET /api/repos/{id}/mcp-config",
+		"GET /api/repos/{id}/tools",
+		"PUT /api/repos/{id}",
+		"PUT /api/repos/{id}/metadata",
+		"POST /api/repos/{id}/generate",
+		"POST /api/repos/{id}/analyze",
+		"POST /api/repos/{id}/refresh-readme",
+		"POST /api/repos/{id}/verify",
+		"PUT /api/repos/{id}/preferred",
+		"DELETE /api/repos/{id}/preferred",
+		"POST /api/repos/{id}/approve",
+		"POST /api/repos/{id}/reject",
+		"GET /api/repos/{id}/staging",
+		"POST /api/repos/{id}/staging/promote",
+	}
+	for _, route := range routes {
+		for _, id := range []string{"abc", "1.5", "-"} {
+			method, pattern, _ := strings.Cut(route, " ")
+			target := strings.Replace(pattern, "{id}", id, 1) + "?dryRun=true"
+			t.Run(route+" "+id, func(t *testing.T) {
+				r := httptest.NewRequest(method, target, strings.NewReader("{}"))
+				r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, r)
+				if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid repository id") {
+					t.Errorf("%s %s = %d %s, want 400 for the id", method, target, w.Code, strings.TrimSpace(w.Body.String()))
+				}
+			})
+		}
+	}
+}
+
+// unencodable fails to marshal, standing in for an error partway through a list.
+type unencodable struct{}
+
+func (unencodable) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot encode")
+}
+
+func TestWriteListEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeList[types.RepoInfo](w, httptest.NewRequest(http.MethodGet, "/api/repos", nil), nil, 0)
+
+	if got := strings.TrimSpace(w.Body.String()); got != "[]" {
+		t.Errorf("body = %q, want []", got)
+	}
+	if got := w.Header().Get("X-Total-Count"); got != "0" {
+		t.Errorf("X-Total-Count = %q, want 0", got)
+	}
+}
+
+func TestWriteListEncodingError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeList(w, httptest.NewRequest(http.MethodGet, "/api/repos", nil), []any{"first", unencodable{}}, 2)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if strings.HasPrefix(w.Body.String(), "[") {
+		t.Errorf("body %q starts a partial array", w.Body.String())
+	}
+	if got := w.Header().Get("X-Total-Count"); got != "" {
+		t.Errorf("X-Total-Count = %q on an error", got)
+	}
+}
+
+func TestListHandlersEmpty(t *testing.T) {
+	newTestDB(t)
+
+	tests := []struct {
+		handler http.HandlerFunc
+		target  string
+	}{
+		{getReposHandler, "/api/repos"},
+		{searchReposHandler, "/api/search?q=nothing"},
+		{getPendingReposHandler, "/api/repos/pending"},
+		{getStaleReposHandler, "/api/repos/stale"},
+		{getFailedAnalysisHandler, "/api/repos/failed-analysis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+				t.Errorf("GET %s = %d %q, want 200 []", tt.target, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("X-Total-Count"); got != "0" {
+				t.Errorf("X-Total-Count = %q, want 0", got)
+			}
+		})
+	}
+}
+
+func TestListHandlerScanErrorMidway(t *testing.T) {
+	newTestDB(t)
+
+	insertTestRepo(t, "owner/good", map[string]any{"stars": 10})
+	// SQLite keeps the text, so scanning the second row fails after the first one succeeded
+	insertTestRepo(t, "owner/bad", map[string]any{"stars": "many"})
+
+	w := httptest.NewRecorder()
+	getReposHandler(w, httptest.NewRequest(http.MethodGet, "/api/repos?sort=name&order=desc", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Error scanning repository") {
+		t.Errorf("GET /api/repos = %d %q, want a 500 for the scan error", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "owner/good") || strings.HasPrefix(w.Body.String(), "[") {
+		t.Errorf("body %q contains a partial array", w.Body.String())
+	}
+}
+
+func TestGenerateErrorsCarryRequestID(t *testing.T) {
+	newTestDB(t)
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+	useOpenAI(t, func(string) *types.MCPServerManifest { return &types.MCPServerManifest{} })
+	id := insertTestRepo(t, "owner/library", map[string]any{"readme_content": "A library", "metadata": "{}"})
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+	handler := requestIDMiddleware(mux)
+
+	tests := []struct {
+		name       string
+		id         string
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "missing repository", id: "999999", wantCode: http.StatusNotFound, wantStatus: "error"},
+		{name: "no server config", id: strconv.Itoa(id), wantCode: http.StatusUnprocessableEntity, wantStatus: "no_mcp_server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/repos/"+tt.id+"/generate", nil)
+			r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+			r.Header.Set("X-Request-ID", "test-request")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding %q: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.wantCode || body["status"] != tt.wantStatus {
+				t.Errorf("got %d %v, want %d with status %q", w.Code, body, tt.wantCode, tt.wantStatus)
+			}
+			if body["requestId"] != "test-request" || body["error"] == "" {
+				t.Errorf("body = %v, want the error and the request id", body)
+			}
+		})
+	}
+}
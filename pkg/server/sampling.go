@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// scrapeSampleFraction is the share of the matched repositories a full scrape analyzes,
+// SCRAPE_SAMPLE_FRACTION (default 1, all of them).
+var scrapeSampleFraction = sync.OnceValue(func() float64 {
+	value := os.Getenv("SCRAPE_SAMPLE_FRACTION")
+	if value == "" {
+		return 1
+	}
+	fraction, err := strconv.ParseFloat(value, 64)
+	if err != nil || fraction <= 0 || fraction > 1 {
+		slog.Warn("Invalid SCRAPE_SAMPLE_FRACTION, analyzing all repositories", "fraction", value)
+		return 1
+	}
+	return fraction
+})
+
+// samplingEnabled reports whether a full scrape has to choose which repositories to analyze,
+// because it may only analyze a fraction of them or may run out of tokens.
+func samplingEnabled(budget *tokenBudget) bool {
+	return scrapeSampleFraction() < 1 || budget.limit > 0
+}
+
+// scrapeCandidate is a matched repository with what it is prioritized by.
+type scrapeCandidate struct {
+	result   *github.CodeResult
+	stars    int
+	pushedAt time.Time
+}
+
+func candidateKey(result *github.CodeResult) string {
+	return result.GetRepository().GetFullName() + ":" + result.GetPath()
+}
+
+// sampleRepos merges the repositories left pending by earlier scrapes into repos and orders them
+// by stars, then by most recent push. The top SCRAPE_SAMPLE_FRACTION is returned to be analyzed
+// now and the rest is deferred.
+//
+// Stored servers whose README is unchanged are skipped by processRepo without spending tokens, so
+// they are always part of the sample and don't count towards the fraction. Code search doesn't
+// return star counts or push times, so they come from the stored servers, from the pending ones,
+// or from GitHub for repositories new to the catalog.
+func sampleRepos(ctx context.Context, repos []*github.CodeResult, force bool) (sample, deferred []scrapeCandidate, err error) {
+	known, err := storedPriorities()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byKey := make(map[string]int)
+	unchanged := make(map[string]bool)
+	unknown := make(map[string]bool)
+	var candidates []scrapeCandidate
+	for _, repo := range repos {
+		candidate := scrapeCandidate{
+			result:   repo,
+			stars:    repo.GetRepository().GetStargazersCount(),
+			pushedAt: repo.GetRepository().GetPushedAt().Time,
+		}
+		stored, ok := known[serverFullName(repo.GetRepository().GetFullName(), repo.GetPath())]
+		switch {
+		case ok && !force && repo.GetSHA() != "" && stored.readmeSHA == repo.GetSHA():
+			unchanged[candidateKey(repo)] = true
+			sample = append(sample, candidate)
+			continue
+		case ok:
+			candidate.stars = max(candidate.stars, stored.stars)
+			if candidate.pushedAt.IsZero() {
+				candidate.pushedAt = stored.pushedAt
+			}
+		default:
+			unknown[candidateKey(repo)] = true
+		}
+		byKey[candidateKey(repo)] = len(candidates)
+		candidates = append(candidates, candidate)
+	}
+
+	pending, err := loadPendingRepos()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, candidate := range pending {
+		key := candidateKey(candidate.result)
+		if unchanged[key] {
+			continue
+		}
+		delete(unknown, key)
+		i, ok := byKey[key]
+		if !ok {
+			byKey[key] = len(candidates)
+			candidates = append(candidates, candidate)
+			continue
+		}
+		candidates[i].stars = max(candidates[i].stars, candidate.stars)
+		if candidates[i].pushedAt.IsZero() {
+			candidates[i].pushedAt = candidate.pushedAt
+		}
+	}
+	fetchPriorities(ctx, candidates, unknown)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].stars != candidates[j].stars {
+			return candidates[i].stars > candidates[j].stars
+		}
+		return candidates[i].pushedAt.After(candidates[j].pushedAt)
+	})
+
+	n := int(math.Ceil(scrapeSampleFraction() * float64(len(candidates))))
+	slog.Info("Sampled repositories to analyze", "matched", len(repos), "pending", len(pending), "unchanged", len(sample), "sampled", n, "deferred", len(candidates)-n)
+	return append(sample, candidates[:n]...), candidates[n:], nil
+}
+
+// fetchPriorities looks up the stars and last push of the candidates whose key is in unknown,
+// once per repository. A repository GitHub can't return is ranked by what code search reported.
+func fetchPriorities(ctx context.Context, candidates []scrapeCandidate, unknown map[string]bool) {
+	fetched := make(map[string]*github.Repository)
+	for i := range candidates {
+		if !unknown[candidateKey(candidates[i].result)] || ctx.Err() != nil {
+			continue
+		}
+		repository := candidates[i].result.GetRepository()
+		githubRepo, ok := fetched[repository.GetFullName()]
+		if !ok {
+			err := utils.GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+				githubRepo, resp, err = githubClient.Repositories.Get(ctx, repository.GetOwner().GetLogin(), repository.GetName())
+				return resp, err
+			})
+			if err != nil {
+				slog.Warn("Error getting repository to prioritize", "repo", repository.GetFullName(), "error", err)
+			}
+			fetched[repository.GetFullName()] = githubRepo
+		}
+		if githubRepo == nil {
+			continue
+		}
+		candidates[i].stars = max(candidates[i].stars, githubRepo.GetStargazersCount())
+		if candidates[i].pushedAt.IsZero() {
+			candidates[i].pushedAt = githubRepo.GetPushedAt().Time
+		}
+	}
+}
+
+// storedPriority is what a server already in the catalog is prioritized by.
+type storedPriority struct {
+	stars     int
+	pushedAt  time.Time
+	readmeSHA string
+}
+
+// storedPriorities returns the stars, last push and README SHA of the servers in the catalog by
+// full name.
+func storedPriorities() (map[string]storedPriority, error) {
+	rows, err := db.Query(`SELECT full_name, COALESCE(stars, 0), pushed_at, COALESCE(readme_sha, '') FROM repositories`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying repository stars: %v", err)
+	}
+	defer rows.Close()
+
+	known := make(map[string]storedPriority)
+	for rows.Next() {
+		var (
+			fullName string
+			stored   storedPriority
+			pushedAt sql.NullTime
+		)
+		if err := rows.Scan(&fullName, &stored.stars, &pushedAt, &stored.readmeSHA); err != nil {
+			return nil, fmt.Errorf("error scanning repository stars: %v", err)
+		}
+		stored.pushedAt = pushedAt.Time
+		known[fullName] = stored
+	}
+	return known, rows.Err()
+}
+
+// loadPendingRepos returns the repositories earlier scrapes deferred.
+func loadPendingRepos() ([]scrapeCandidate, error) {
+	rows, err := db.Query(`SELECT repo, path, stars, pushed_at FROM scrape_pending`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pending repositories: %v", err)
+	}
+	defer rows.Close()
+
+	var pending []scrapeCandidate
+	for rows.Next() {
+		var (
+			fullName, path string
+			candidate      scrapeCandidate
+			pushedAt       sql.NullTime
+		)
+		if err := rows.Scan(&fullName, &path, &candidate.stars, &pushedAt); err != nil {
+			return nil, fmt.Errorf("error scanning pending repository: %v", err)
+		}
+		result := codeResultFor(fullName, path)
+		if result == nil {
+			slog.Warn("Skipping pending repository with invalid name", "repo", fullName)
+			continue
+		}
+		candidate.result = result
+		candidate.pushedAt = pushedAt.Time
+		pending = append(pending, candidate)
+	}
+	return pending, rows.Err()
+}
+
+// savePendingRepos removes the repositories this scrape got to from the pending ones and stores
+// the ones it deferred, so that the next scrape picks them up.
+func savePendingRepos(done, deferred []scrapeCandidate) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, candidate := range done {
+		if _, err := tx.Exec(`DELETE FROM scrape_pending WHERE repo = $1 AND path = $2`,
+			candidate.result.GetRepository().GetFullName(), candidate.result.GetPath()); err != nil {
+			return fmt.Errorf("error removing pending repository: %v", err)
+		}
+	}
+	for _, candidate := range deferred {
+		var pushedAt *time.Time
+		if !candidate.pushedAt.IsZero() {
+			pushedAt = &candidate.pushedAt
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO scrape_pending (repo, path, stars, pushed_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (repo, path) DO UPDATE SET stars = excluded.stars, pushed_at = excluded.pushed_at
+		`, candidate.result.GetRepository().GetFullName(), candidate.result.GetPath(), candidate.stars, pushedAt); err != nil {
+			return fmt.Errorf("error saving pending repository: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// scrapeSampled analyzes the sample of the matched repositories and records what is left pending.
+// Failing to record it is only logged, since the repositories are found again by later scrapes.
+func scrapeSampled(ctx context.Context, repos []*github.CodeResult, force bool, budget *tokenBudget) (map[string]bool, error) {
+	sample, deferred, err := sampleRepos(ctx, repos, force)
+	if err != nil {
+		return nil, err
+	}
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		status.Found = len(sample) + len(deferred)
+	})
+
+	results := make([]*github.CodeResult, 0, len(sample))
+	for _, candidate := range sample {
+		results = append(results, candidate.result)
+	}
+	addedRepos, notStarted := processRepos(ctx, results, force, budget)
+
+	// Whatever the budget or a shutdown kept from being analyzed stays pending as well
+	started := make([]scrapeCandidate, 0, len(sample))
+	for _, candidate := range sample {
+		if notStarted[candidateKey(candidate.result)] {
+			deferred = append(deferred, candidate)
+		} else {
+			started = append(started, candidate)
+		}
+	}
+	if err := savePendingRepos(started, deferred); err != nil {
+		slog.Error("Error saving pending repositories", "error", err)
+	}
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		status.Pending = len(deferred)
+	})
+	if len(deferred) > 0 {
+		slog.Info("Repositories left for a later scrape", "pending", len(deferred))
+	}
+	return addedRepos, nil
+}
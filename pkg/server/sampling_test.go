@@ -0,0 +1,65 @@
+//go:build cgo
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/google/go-github/v60/github"
+)
+
+func TestSampleReposRanksNewRepositories(t *testing.T) {
+	newTestDB(t)
+	var fetched []string
+	newFakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/new":
+			w.Write([]byte(`{"full_name":"owner/new","stargazers_count":500,"pushed_at":"2026-01-01T00:00:00Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	insertTestRepo(t, "owner/known", map[string]any{"stars": 10, "readme_sha": "old"})
+	insertTestRepo(t, "owner/same", map[string]any{"stars": 1000, "readme_sha": "same"})
+
+	result := func(fullName, path, sha string) *github.CodeResult {
+		result := codeResultFor(fullName, path)
+		result.SHA = github.String(sha)
+		return result
+	}
+	sample, deferred, err := sampleRepos(context.Background(), []*github.CodeResult{
+		result("owner/known", "README.md", "new"),
+		result("owner/new", "README.md", "a"),
+		result("owner/new", "docs/README.md", "b"),
+		result("owner/same", "README.md", "same"),
+	}, false)
+	if err != nil {
+		t.Fatalf("sampleRepos() error = %v", err)
+	}
+	if len(deferred) != 0 {
+		t.Errorf("deferred %d repositories with the whole sample analyzed", len(deferred))
+	}
+
+	var got []string
+	for _, candidate := range sample {
+		got = append(got, candidate.result.GetRepository().GetFullName()+"/"+candidate.result.GetPath())
+	}
+	// The unchanged README comes first without taking up the sample, the new repository ranks by
+	// the stars GitHub reported
+	want := []string{"owner/same/README.md", "owner/new/README.md", "owner/new/docs/README.md", "owner/known/README.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("sample = %q, want %q", got, want)
+	}
+	if !slices.Equal(fetched, []string{"/repos/owner/new"}) {
+		t.Errorf("fetched %q, want only the new repository once", fetched)
+	}
+	if sample[1].pushedAt.IsZero() {
+		t.Error("push time of the new repository was not fetched")
+	}
+}
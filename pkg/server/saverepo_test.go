@@ -0,0 +1,307 @@
+//go:build cgo
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+func TestSaveRepoReplacesInvalidJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		tools    string
+		manifest string
+	}{
+		{name: "empty", metadata: "", tools: "", manifest: ""},
+		{name: "whitespace", metadata: "  ", tools: "\n", manifest: "\t"},
+		{name: "malformed", metadata: `{"categories":`, tools: `[{"name":`, manifest: "not json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+
+			name, err := utils.SaveRepo(db, types.RepoInfo{
+				FullName:        "owner/repo",
+				Path:            "README.md",
+				Metadata:        tt.metadata,
+				ToolDefinitions: tt.tools,
+				Manifest:        tt.manifest,
+			}, false)
+			if err != nil {
+				t.Fatalf("SaveRepo() error = %v", err)
+			}
+
+			var metadata, tools, manifest string
+			if err := db.QueryRow(`SELECT metadata, tool_definitions, manifest FROM repositories WHERE full_name = $1`, name).
+				Scan(&metadata, &tools, &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if metadata != "{}" || tools != "{}" || manifest != "{}" {
+				t.Errorf("stored metadata %q, tool_definitions %q, manifest %q, want {} for all", metadata, tools, manifest)
+			}
+		})
+	}
+}
+
+func TestSaveRepoUpdateKeepsStoredJSON(t *testing.T) {
+	const (
+		metadata = `{"categories":"Developer Tools"}`
+		tools    = `[{"name":"search"}]`
+		manifest = `[{"command":"npx","args":["-y","curated"],"env":[{"key":"API_KEY"}],"preferred":true}]`
+	)
+	tests := []struct {
+		name     string
+		proposed bool
+		value    string
+	}{
+		{name: "empty", value: ""},
+		{name: "malformed", value: "not json"},
+		{name: "malformed proposed", proposed: true, value: `{"categories":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+			if _, err := utils.SaveRepo(db, types.RepoInfo{FullName: "owner/repo", Metadata: metadata, ToolDefinitions: tools, Manifest: manifest}, false); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := utils.SaveRepo(db, types.RepoInfo{
+				FullName:         "owner/repo",
+				Description:      "updated",
+				Metadata:         tt.value,
+				ToolDefinitions:  tt.value,
+				Manifest:         tt.value,
+				ProposedManifest: `[{"command":"uvx","args":["proposed"]}]`,
+			}, tt.proposed)
+			if err != nil {
+				t.Fatalf("SaveRepo() error = %v", err)
+			}
+
+			var description, storedMetadata, storedTools, storedManifest string
+			if err := db.QueryRow(`SELECT description, metadata, tool_definitions, manifest FROM repositories WHERE full_name = 'owner/repo'`).
+				Scan(&description, &storedMetadata, &storedTools, &storedManifest); err != nil {
+				t.Fatal(err)
+			}
+			if description != "updated" {
+				t.Errorf("description = %q, want the update saved", description)
+			}
+			if storedMetadata != metadata || storedTools != tools || storedManifest != manifest {
+				t.Errorf("stored metadata %q, tool_definitions %q, manifest %q, want them kept", storedMetadata, storedTools, storedManifest)
+			}
+			var env int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM manifest_env`).Scan(&env); err != nil || env != 1 {
+				t.Errorf("manifest_env has %d rows (%v), want the kept manifest's 1", env, err)
+			}
+		})
+	}
+}
+
+func TestSaveRepoKeepsValidJSON(t *testing.T) {
+	newTestDB(t)
+
+	const metadata = `{"categories":"Developer Tools"}`
+	name, err := utils.SaveRepo(db, types.RepoInfo{FullName: "owner/repo", Metadata: metadata}, false)
+	if err != nil {
+		t.Fatalf("SaveRepo() error = %v", err)
+	}
+	var stored string
+	if err := db.QueryRow(`SELECT metadata FROM repositories WHERE full_name = $1`, name).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != metadata {
+		t.Errorf("stored metadata %q, want %q", stored, metadata)
+	}
+}
+
+func TestUpdateRepoKeepsDataWhenAnalysisFails(t *testing.T) {
+	newTestDB(t)
+
+	const (
+		manifest = `[{"command":"npx","args":["-y","curated-server"],"env":[],"preferred":true}]`
+		tools    = `[{"name":"search","description":"Search"}]`
+	)
+	id := insertTestRepo(t, "owner/repo", map[string]any{
+		"manifest":         manifest,
+		"tool_definitions": tools,
+		"metadata":         "{}",
+		"analyzed_sha":     "old",
+	})
+	failing := newFakeOpenAI(t, func(string) *types.MCPServerManifest { return nil })
+
+	// AddRepo passes only what GitHub returned, without the stored manifest
+	_, err := utils.UpdateRepo(context.Background(), types.RepoInfo{
+		FullName:      "owner/repo",
+		ReadmeContent: "new readme",
+		Metadata:      "{}",
+		AnalyzedSHA:   "new",
+	}, false, failing, "owner/repo", "new readme", db, nil)
+	if err == nil {
+		t.Fatal("UpdateRepo() succeeded, want the analysis error")
+	}
+
+	var storedManifest, storedTools, sha string
+	var version int
+	if err := db.QueryRow(`SELECT manifest, tool_definitions, analyzed_sha, version FROM repositories WHERE id = $1`, id).
+		Scan(&storedManifest, &storedTools, &sha, &version); err != nil {
+		t.Fatal(err)
+	}
+	if storedManifest != manifest || storedTools != tools {
+		t.Errorf("manifest %q and tool_definitions %q were overwritten", storedManifest, storedTools)
+	}
+	if sha != "old" || version != 1 {
+		t.Errorf("analyzed_sha = %q, version = %d, want old and 1", sha, version)
+	}
+
+	var attempts int
+	if err := db.QueryRow(`SELECT analysis_attempts FROM analysis_failures WHERE full_name = 'owner/repo'`).Scan(&attempts); err != nil || attempts != 1 {
+		t.Errorf("analysis failure recorded %d times (%v), want 1", attempts, err)
+	}
+}
+
+func TestUpdateRepoStoresPlatforms(t *testing.T) {
+	tests := []struct {
+		name   string
+		readme string
+		// platforms is what the model extracts from the README
+		platforms []string
+		want      string
+	}{
+		{
+			name:      "owner/macos-only",
+			readme:    "This server drives AppleScript, so it only runs on macOS (OS X 10.15 or later).",
+			platforms: []string{"macOS", "OS X"},
+			want:      "macos",
+		},
+		{
+			name:      "owner/unix",
+			readme:    "Works on Linux and Mac. On Windows, run it inside WSL.",
+			platforms: []string{"Linux", "mac", "Windows (WSL)"},
+			want:      "macos,linux,wsl",
+		},
+		{
+			name:      "owner/unknown",
+			readme:    "Tested on FreeBSD and Linux.",
+			platforms: []string{"FreeBSD", "linux"},
+			want:      "linux",
+		},
+		{
+			name:   "owner/anywhere",
+			readme: "Runs anywhere Node.js does.",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+
+			client := newFakeOpenAI(t, func(prompt string) *types.MCPServerManifest {
+				if !strings.Contains(prompt, tt.readme) {
+					return nil
+				}
+				return &types.MCPServerManifest{
+					Configs:   []types.MCPServerConfig{{Command: "npx", Args: []string{"-y", "server-foo"}}},
+					Platforms: tt.platforms,
+				}
+			})
+			name, err := utils.UpdateRepo(context.Background(), types.RepoInfo{
+				FullName:        tt.name,
+				ReadmeContent:   tt.readme,
+				Metadata:        "{}",
+				ToolDefinitions: `[{"name":"search"}]`,
+			}, false, client, tt.name, tt.readme, db, nil)
+			if err != nil {
+				t.Fatalf("UpdateRepo() error = %v", err)
+			}
+
+			var platforms string
+			if err := db.QueryRow(`SELECT platforms FROM repositories WHERE full_name = $1`, name).Scan(&platforms); err != nil {
+				t.Fatal(err)
+			}
+			if platforms != tt.want {
+				t.Errorf("stored platforms %q, want %q", platforms, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateRepoKeepsChosenPreferred(t *testing.T) {
+	const chosen = "docker run -i owner/image"
+	tests := []struct {
+		name   string
+		force  bool
+		column string
+	}{
+		{name: "proposed", force: false, column: "proposed_manifest"},
+		{name: "forced", force: true, column: "manifest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+			// A forced rescrape searches the repository for tool definitions again
+			newFakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"total_count":0,"items":[]}`))
+			})
+			searchLimiter := utils.GitHubSearchLimiter
+			utils.GitHubSearchLimiter = utils.NewRateLimiter(time.Millisecond, 10)
+			t.Cleanup(func() { utils.GitHubSearchLimiter = searchLimiter })
+
+			const manifest = `[{"command":"npx","args":["-y","server-foo"],"env":[]},{"command":"docker","args":["run","-i","owner/image"],"env":[],"preferred":true}]`
+			insertTestRepo(t, "owner/repo", map[string]any{
+				"manifest":         manifest,
+				"tool_definitions": `[{"name":"search"}]`,
+				"metadata":         "{}",
+				"preferred_key":    chosen,
+			})
+			// The rescrape finds npx, which MarkPreferred would rank above docker
+			client := newFakeOpenAI(t, func(string) *types.MCPServerManifest {
+				return &types.MCPServerManifest{Configs: []types.MCPServerConfig{
+					{Command: "npx", Args: []string{"-y", "server-foo"}},
+					{Command: "uvx", Args: []string{"server-foo"}},
+					{Command: "docker", Args: []string{"run", "-i", "owner/image"}},
+				}}
+			})
+			name, err := utils.UpdateRepo(context.Background(), types.RepoInfo{
+				FullName:        "owner/repo",
+				ReadmeContent:   "readme",
+				Manifest:        manifest,
+				Metadata:        "{}",
+				ToolDefinitions: `[{"name":"search"}]`,
+			}, tt.force, client, "owner/repo", "readme", db, githubClient)
+			if err != nil {
+				t.Fatalf("UpdateRepo() error = %v", err)
+			}
+
+			var stored, key string
+			if err := db.QueryRow(`SELECT `+tt.column+`, COALESCE(preferred_key, '') FROM repositories WHERE full_name = $1`, name).
+				Scan(&stored, &key); err != nil {
+				t.Fatal(err)
+			}
+			var configs []types.MCPServerConfig
+			if err := json.Unmarshal([]byte(stored), &configs); err != nil {
+				t.Fatalf("decoding %s %q: %v", tt.column, stored, err)
+			}
+			var preferred []string
+			for _, config := range configs {
+				if config.Preferred {
+					preferred = append(preferred, utils.ConfigKey(config))
+				}
+			}
+			if len(preferred) != 1 || preferred[0] != chosen {
+				t.Errorf("preferred configs in %s = %q, want only %q", tt.column, preferred, chosen)
+			}
+			if key != chosen {
+				t.Errorf("preferred_key = %q, want %q", key, chosen)
+			}
+		})
+	}
+}
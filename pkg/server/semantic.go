@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+// semanticSearchLimiter bounds how often semantic search can spend OpenAI credits on embedding a
+// query, SEMANTIC_SEARCH_RATE_PER_MINUTE (default 60). Cached queries don't count.
+var semanticSearchLimiter = sync.OnceValue(func() *utils.RateLimiter {
+	perMinute, _ := strconv.Atoi(os.Getenv("SEMANTIC_SEARCH_RATE_PER_MINUTE"))
+	if perMinute <= 0 {
+		perMinute = 60
+	}
+	return utils.NewRateLimiter(time.Minute/time.Duration(perMinute), perMinute)
+})
+
+// maxCachedQueries bounds the number of query embeddings kept in queryEmbeddings.
+const maxCachedQueries = 1000
+
+// queryEmbeddings keeps the embeddings of recent search queries, so repeated searches don't call
+// OpenAI again.
+var queryEmbeddings = struct {
+	mu      sync.Mutex
+	entries map[string][]float32
+	order   []string
+}{entries: make(map[string][]float32)}
+
+// embedQuery returns the embedding of a search query, from the cache if it was searched for
+// recently. ok is false when the query isn't cached and the rate limit is reached.
+func embedQuery(ctx context.Context, query string) (embedding []float32, ok bool, err error) {
+	key := strings.ToLower(strings.Join(strings.Fields(query), " "))
+
+	queryEmbeddings.mu.Lock()
+	embedding, cached := queryEmbeddings.entries[key]
+	queryEmbeddings.mu.Unlock()
+	if cached {
+		return embedding, true, nil
+	}
+	if !semanticSearchLimiter().Allow() {
+		return nil, false, nil
+	}
+
+	embedding, err = utils.Embed(ctx, openaiClient, key)
+	if err != nil {
+		return nil, true, err
+	}
+
+	queryEmbeddings.mu.Lock()
+	defer queryEmbeddings.mu.Unlock()
+	if _, cached := queryEmbeddings.entries[key]; !cached {
+		if len(queryEmbeddings.order) >= maxCachedQueries {
+			delete(queryEmbeddings.entries, queryEmbeddings.order[0])
+			queryEmbeddings.order = queryEmbeddings.order[1:]
+		}
+		queryEmbeddings.entries[key] = embedding
+		queryEmbeddings.order = append(queryEmbeddings.order, key)
+	}
+	return embedding, true, nil
+}
+
+// backfillEmbeddings embeds the repositories stored before embeddings were enabled, so semantic
+// search covers the whole catalog rather than only what was analyzed since.
+func backfillEmbeddings(ctx context.Context) {
+	if !utils.EmbeddingsEnabled {
+		return
+	}
+	stored, err := utils.BackfillEmbeddings(ctx, db, openaiClient, 100)
+	if err != nil {
+		slog.Error("Error backfilling embeddings", "stored", stored, "error", err)
+		return
+	}
+	if stored > 0 {
+		slog.Info("Backfilled embeddings", "stored", stored)
+	}
+}
+
+// semanticSearchHandler returns the repositories whose name and description are closest in
+// meaning to ?q=, by cosine distance between embeddings. Without pgvector it falls back to the
+// substring search of searchReposHandler.
+func semanticSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+
+	if !utils.EmbeddingsEnabled {
+		searchReposHandler(w, r)
+		return
+	}
+
+	limit := 20
+	if val, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && val > 0 {
+		limit = val
+	}
+
+	embedding, ok, err := embedQuery(r.Context(), query)
+	if !ok {
+		http.Error(w, "Too many search requests, try again later", http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error embedding search query: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	rows, err := reader().Query(`
+		SELECT id, path, full_name, display_name, url, description, stars, language, manifest, COALESCE(icon, '')
+		FROM repositories
+		WHERE embedding IS NOT NULL
+		ORDER BY embedding <=> $1::vector
+		LIMIT $2
+	`, utils.VectorLiteral(embedding), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error searching repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	repos := make([]types.RepoInfo, 0)
+	for rows.Next() {
+		var repo types.RepoInfo
+		err := rows.Scan(
+			&repo.ID,
+			&repo.Path,
+			&repo.FullName,
+			&repo.DisplayName,
+			&repo.URL,
+			&repo.Description,
+			&repo.Stars,
+			&repo.Language,
+			&repo.Manifest,
+			&repo.Icon,
+		)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error scanning repository: %v", err), http.StatusInternalServerError)
+			return
+		}
+		repos = append(repos, repo)
+	}
+
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Error iterating repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, repos, len(repos))
+}
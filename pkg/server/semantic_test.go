@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestEmbedQueryCachesQueries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.EmbeddingResponse{
+			Data: []openai.Embedding{{Index: 0, Embedding: []float32{0.1, 0.2}}},
+		})
+	}))
+	defer srv.Close()
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = srv.URL + "/v1"
+	previous := openaiClient
+	openaiClient = openai.NewClientWithConfig(config)
+	t.Cleanup(func() { openaiClient = previous })
+
+	// Queries that only differ in case and spacing share an embedding
+	for _, query := range []string{"Postgres  server", "postgres server", " POSTGRES server "} {
+		embedding, ok, err := embedQuery(context.Background(), query)
+		if !ok || err != nil || len(embedding) != 2 {
+			t.Fatalf("embedQuery(%q) = %v, %v, %v", query, embedding, ok, err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("embedded the query %d times, want 1", got)
+	}
+}
@@ -4,81 +4,105 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/google/go-github/v60/github"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sashabaranov/go-openai"
 	"golang.org/x/oauth2"
 )
 
 var (
 	db           *sql.DB
+	readDB       *sql.DB
 	githubClient *github.Client
 	openaiClient *openai.Client
+
+	// dbDriver is "postgres" or, for local development, "sqlite3"
+	dbDriver = "postgres"
+
+	// shutdownCtx is cancelled when the process is asked to stop, ending background work such as
+	// an in-progress scrape.
+	shutdownCtx = context.Background()
 )
 
 func Run() {
 	// Load environment variables
 	err := godotenv.Load()
+	initLogger()
 	if err != nil {
-		log.Println("Warning: Error loading .env file, using environment variables")
+		slog.Warn("Error loading .env file, using environment variables")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownCtx = ctx
+
+	// Load secrets mounted as files
+	if err := loadSecretFiles(); err != nil {
+		fatal("Error loading secret files", "error", err)
+	}
+
+	// Report every missing setting at once rather than failing on the first
+	if err := checkRequiredConfig(); err != nil {
+		fatal(err.Error())
+	}
+
+	// Load the category taxonomy for this deployment
+	if err := loadCategories(); err != nil {
+		fatal("Error loading categories", "error", err)
+	}
+
+	// Load the code searches used to find tool definitions
+	if err := loadToolSearchPatterns(); err != nil {
+		fatal("Error loading tool search patterns", "error", err)
 	}
 
 	// Initialize database
-	initDB()
+	if err := initDB(); err != nil {
+		fatal("Error initializing database", "error", err)
+	}
 	defer db.Close()
+	if err := initReadDB(); err != nil {
+		fatal("Error initializing read database", "error", err)
+	}
+	if readDB != nil {
+		defer readDB.Close()
+	}
 
 	// Initialize GitHub client
-	initGitHubClient()
+	if err := initGitHubClient(); err != nil {
+		fatal("Error initializing GitHub client", "error", err)
+	}
 
 	// Initialize OpenAI client
-	initOpenAIClient()
+	if err := initOpenAIClient(); err != nil {
+		fatal("Error initializing OpenAI client", "error", err)
+	}
 
 	startCronJobs()
 
 	// Create API routes
 	mux := http.NewServeMux()
 
-	// Add CORS middleware
-	corsMiddleware := func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Set CORS headers
-			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5175")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-			// Handle preflight requests
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			// Call the next handler
-			next.ServeHTTP(w, r)
-		})
-	}
-
 	// Wrap your handlers with CORS middleware
-	corsHandler := corsMiddleware(mux)
+	corsHandler := requestIDMiddleware(metricsMiddleware(corsMiddleware(mux)))
+	registerDBMetrics()
 
-	mux.HandleFunc("GET /api/repos", getReposHandler)
-	mux.HandleFunc("GET /api/repos/count", getReposCountHandler)
-	mux.HandleFunc("GET /api/search", searchReposHandler)
-	mux.HandleFunc("GET /api/search-readme", searchReposByReadmeHandler)
-	mux.HandleFunc("GET /api/repos/{id}", getRepoHandler)
-	mux.HandleFunc("PUT /api/repos/{id}", updateRepoHandler)
-	mux.HandleFunc("PUT /api/repos/{id}/metadata", updateRepoMetadataHandler)
-	mux.HandleFunc("POST /api/repos/{id}/generate", generateConfigForSpecificRepoHandler)
-	mux.HandleFunc("POST /api/repos/{id}/approve", approveRepoHandler)
-	mux.HandleFunc("POST /api/repos/rescrape", rescrapeHandler)
-	mux.HandleFunc("POST /api/repos/add", addRepoHandler)
+	registerRoutes(mux)
 
 	// Create a file server for the static files
 	fs := http.FileServer(http.Dir("./frontend/dist"))
@@ -104,57 +128,315 @@ func Run() {
 	if port == "" {
 		port = "8080"
 	}
-	log.Printf("Server starting on port %s...", port)
-	log.Fatal(http.ListenAndServe(":"+port, corsHandler))
+	srv := &http.Server{Addr: ":" + port, Handler: corsHandler}
+	go func() {
+		<-ctx.Done()
+		slog.Info("Shutting down server")
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(timeoutCtx); err != nil {
+			slog.Error("Error shutting down server", "error", err)
+		}
+	}()
+
+	slog.Info("Server starting", "port", port)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		fatal("Server stopped", "error", err)
+	}
+	slog.Info("Server stopped")
 }
 
-func initDB() {
-	dsn := os.Getenv("POSTGRES_DSN")
+// registerRoutes adds the API and metrics routes to mux.
+func registerRoutes(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	mux.HandleFunc("GET /api/repos", getReposHandler)
+	mux.HandleFunc("GET /api/repos/count", getReposCountHandler)
+	mux.HandleFunc("GET /api/repos/pending", getPendingReposHandler)
+	mux.HandleFunc("GET /api/repos/failed-analysis", getFailedAnalysisHandler)
+	mux.HandleFunc("GET /api/repos/stale", getStaleReposHandler)
+	mux.HandleFunc("GET /api/repos/recent", getRecentReposHandler)
+	mux.HandleFunc("DELETE /api/repos/failed-analysis", clearFailedAnalysisHandler)
+	mux.HandleFunc("GET /api/search", searchReposHandler)
+	mux.HandleFunc("GET /api/search-readme", searchReposByReadmeHandler)
+	mux.HandleFunc("GET /api/semantic-search", semanticSearchHandler)
+	mux.HandleFunc("GET /api/languages", getLanguagesHandler)
+	mux.HandleFunc("GET /api/categories", getCategoriesHandler)
+	mux.HandleFunc("GET /api/env-vars", getEnvVarsHandler)
+	mux.HandleFunc("GET /api/export", exportCatalogHandler)
+	mux.HandleFunc("POST /api/import", importCatalogHandler)
+	mux.HandleFunc("GET /api/owners", getOwnersHandler)
+	mux.HandleFunc("GET /api/owners/{owner}/repos", getOwnerReposHandler)
+	mux.HandleFunc("GET /api/repos/{id}", getRepoHandler)
+	mux.HandleFunc("GET /api/repos/{id}/manifest", getRepoManifestHandler)
+	mux.HandleFunc("GET /api/repos/{id}/history", getRepoHistoryHandler)
+	mux.HandleFunc("GET /api/repos/{id}/related", getRelatedReposHandler)
+	mux.HandleFunc("GET /api/repos/{id}/export", exportRepoHandler)
+	mux.HandleFunc("GET /api/repos/{id}/mcp-config", getRepoMCPConfigHandler)
+	mux.HandleFunc("GET /api/repos/{id}/tools", getRepoToolsHandler)
+	mux.HandleFunc("PUT /api/repos/{id}", updateRepoHandler)
+	mux.HandleFunc("PUT /api/repos/{id}/metadata", updateRepoMetadataHandler)
+	mux.HandleFunc("POST /api/repos/{id}/generate", generateConfigForSpecificRepoHandler)
+	mux.HandleFunc("POST /api/repos/{id}/analyze", analyzeRepoHandler)
+	mux.HandleFunc("POST /api/repos/{id}/refresh-readme", refreshReadmeHandler)
+	mux.HandleFunc("POST /api/repos/{id}/verify", verifyRepoHandler)
+	mux.HandleFunc("PUT /api/repos/{id}/preferred", setPreferredHandler)
+	mux.HandleFunc("DELETE /api/repos/{id}/preferred", setPreferredHandler)
+	mux.HandleFunc("POST /api/repos/{id}/approve", approveRepoHandler)
+	mux.HandleFunc("POST /api/repos/{id}/reject", rejectRepoHandler)
+	mux.HandleFunc("GET /api/repos/{id}/staging", getRepoStagingHandler)
+	mux.HandleFunc("POST /api/repos/{id}/staging/promote", promoteStagingHandler)
+	mux.HandleFunc("POST /api/repos/rescrape", rescrapeHandler)
+	mux.HandleFunc("GET /api/scrape/status", getScrapeStatusHandler)
+	mux.HandleFunc("POST /api/repos/add", addRepoHandler)
+	mux.HandleFunc("POST /api/analyze", analyzeReadmeHandler)
+	mux.HandleFunc("POST /api/repos/categorize", categorizeReposHandler)
+	mux.HandleFunc("POST /api/repos/refresh-icons", refreshIconsHandler)
+	mux.HandleFunc("POST /api/repos/approve-all", approveAllReposHandler)
+	mux.HandleFunc("POST /api/repos/recompute-preferred", recomputePreferredHandler)
+}
+
+// secretEnvVars can alternatively be provided as a file path in <NAME>_FILE, following
+// the Docker and Kubernetes secret conventions.
+var secretEnvVars = []string{
+	"OBOT_CATALOG_SERVER_ACCESS_TOKEN",
+	"GITHUB_TOKEN",
+	"OPENAI_API_KEY",
+}
+
+// checkRequiredConfig returns an error naming all required environment variables that are not
+// set. It runs after loadSecretFiles, so secrets provided as files count as set.
+func checkRequiredConfig() error {
+	var required []string
+	if os.Getenv("DB_DRIVER") != "sqlite3" {
+		required = append(required, "POSTGRES_DSN")
+	}
+	required = append(required, "GITHUB_TOKEN", "OPENAI_API_KEY")
+
+	var missing []string
+	for _, name := range required {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// loadSecretFiles reads each secret whose <NAME>_FILE variable is set and exports it as <NAME>.
+// The file takes precedence when both are set.
+func loadSecretFiles() error {
+	for _, name := range secretEnvVars {
+		path := os.Getenv(name + "_FILE")
+		if path == "" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s_FILE: %v", name, err)
+		}
+		if err := os.Setenv(name, strings.TrimRight(string(content), "\r\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCategories replaces the default category list with the one configured for this deployment.
+// CATEGORIES_FILE points to a JSON array or a file with one category per line; CATEGORIES is a
+// comma separated list. The file takes precedence when both are set.
+func loadCategories() error {
+	var categories []string
+	if path := os.Getenv("CATEGORIES_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading CATEGORIES_FILE: %v", err)
+		}
+		if trimmed := strings.TrimSpace(string(content)); strings.HasPrefix(trimmed, "[") {
+			if err := json.Unmarshal([]byte(trimmed), &categories); err != nil {
+				return fmt.Errorf("error parsing CATEGORIES_FILE: %v", err)
+			}
+		} else {
+			categories = strings.Split(trimmed, "\n")
+		}
+	} else if env := os.Getenv("CATEGORIES"); env != "" {
+		categories = strings.Split(env, ",")
+	} else {
+		categories = types.Categories
+	}
+
+	var result []string
+	for _, category := range categories {
+		if category = strings.TrimSpace(category); category != "" && !slices.Contains(result, category) {
+			result = append(result, category)
+		}
+	}
+	if len(result) == 0 {
+		return fmt.Errorf("the category list is empty")
+	}
+
+	types.Categories = result
+	slog.Info("Using categories", "count", len(result))
+	return nil
+}
+
+// loadToolSearchPatterns replaces the default (extension, marker) pairs searched for tool
+// definitions. TOOL_SEARCH_PATTERNS_FILE points to a JSON array of {"extension", "marker"} objects;
+// TOOL_SEARCH_PATTERNS is a comma separated list of extension:marker pairs. The file takes
+// precedence when both are set.
+func loadToolSearchPatterns() error {
+	var patterns []utils.ToolSearchPattern
+	if path := os.Getenv("TOOL_SEARCH_PATTERNS_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading TOOL_SEARCH_PATTERNS_FILE: %v", err)
+		}
+		if err := json.Unmarshal(content, &patterns); err != nil {
+			return fmt.Errorf("error parsing TOOL_SEARCH_PATTERNS_FILE: %v", err)
+		}
+	} else if env := os.Getenv("TOOL_SEARCH_PATTERNS"); env != "" {
+		for _, pair := range strings.Split(env, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			extension, marker, ok := strings.Cut(pair, ":")
+			if !ok {
+				return fmt.Errorf("invalid TOOL_SEARCH_PATTERNS entry %q, expected extension:marker", pair)
+			}
+			patterns = append(patterns, utils.ToolSearchPattern{Extension: extension, Marker: marker})
+		}
+	} else {
+		return nil
+	}
+
+	if err := utils.SetToolSearchPatterns(patterns); err != nil {
+		return err
+	}
+	slog.Info("Using tool search patterns", "count", len(utils.ToolSearchPatterns))
+	return nil
+}
+
+// dbPingTimeout bounds how long startup waits for the database to accept a connection.
+const dbPingTimeout = 10 * time.Second
+
+func openDB(dsn string) (*sql.DB, error) {
+	var (
+		conn *sql.DB
+		err  error
+	)
+	if dbDriver == "sqlite3" {
+		conn, err = sql.Open(sqliteDriverName, dsn)
+	} else {
+		// Add sslmode=disable to DSN if not already present
+		if !strings.Contains(dsn, "sslmode=") {
+			dsn += "?sslmode=disable"
+		}
+		conn, err = sql.Open("postgres", dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// sql.Open doesn't connect, so an unreachable database would otherwise only show up as
+	// failing requests
+	ctx, cancel := context.WithTimeout(context.Background(), dbPingTimeout)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect within %s: %w", dbPingTimeout, err)
+	}
+	return conn, nil
+}
+
+// initReadDB opens the read replica configured by POSTGRES_READ_DSN, if any.
+func initReadDB() error {
+	dsn := os.Getenv("POSTGRES_READ_DSN")
 	if dsn == "" {
-		log.Fatalf("POSTGRES_DSN environment variable is required")
+		return nil
+	}
+
+	conn, err := openDB(dsn)
+	if err != nil {
+		return fmt.Errorf("error opening read database: %w", err)
 	}
-	// Add sslmode=disable to DSN if not already present
-	if !strings.Contains(dsn, "sslmode=") {
-		dsn += "?sslmode=disable"
+	readDB = conn
+	slog.Info("Serving read-only queries from POSTGRES_READ_DSN")
+	return nil
+}
+
+// reader returns the pool read-only handlers should query: the read replica when one is
+// configured, otherwise the primary. Writes always go to db.
+func reader() *sql.DB {
+	if readDB != nil {
+		return readDB
+	}
+	return db
+}
+
+func initDB() error {
+	if driver := os.Getenv("DB_DRIVER"); driver != "" {
+		if driver != "postgres" && driver != "sqlite3" {
+			return fmt.Errorf("DB_DRIVER must be postgres or sqlite3, got %q", driver)
+		}
+		dbDriver = driver
+	}
+
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dbDriver == "sqlite3" {
+		dsn = os.Getenv("SQLITE_DSN")
+		if dsn == "" {
+			dsn = "file:catalog.db?_foreign_keys=on"
+		}
+	}
+	if dsn == "" {
+		return fmt.Errorf("POSTGRES_DSN environment variable is required")
 	}
 
 	var err error
-	db, err = sql.Open("postgres", dsn)
-	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
-	}
-
-	// Create repositories table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS repositories (
-			id SERIAL PRIMARY KEY,
-			path TEXT,
-			display_name TEXT,
-			full_name TEXT UNIQUE,
-			url TEXT,
-			description TEXT,
-			stars INTEGER,
-			readme_content TEXT,
-			language TEXT,
-			manifest JSONB,
-			icon TEXT,
-			tool_definitions JSONB,
-			metadata JSONB,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
+	db, err = openDB(dsn)
 	if err != nil {
-		log.Fatalf("Error creating repositories table: %v", err)
+		return fmt.Errorf("error opening database: %w", err)
 	}
+
 	if err := applyMigrations(); err != nil {
-		log.Fatalf("Error applying migrations: %v", err)
+		return fmt.Errorf("error applying migrations: %w", err)
+	}
+	return nil
+}
+
+// enableEmbeddings adds the embedding column used by semantic search. pgvector is optional: when
+// the extension isn't available the server runs without embeddings.
+func enableEmbeddings() {
+	if dbDriver != "postgres" {
+		return
+	}
+	_, err := db.Exec(fmt.Sprintf(`
+		CREATE EXTENSION IF NOT EXISTS vector;
+		ALTER TABLE repositories ADD COLUMN IF NOT EXISTS embedding vector(%d);
+	`, utils.EmbeddingDimensions))
+	if err != nil {
+		slog.Warn("pgvector is not available, semantic search will fall back to substring search", "error", err)
+		return
 	}
+	utils.EmbeddingsEnabled = true
 }
 
+// applyMigrations brings the schema up to date and then runs the data fixes that have to happen
+// in Go rather than SQL.
 func applyMigrations() error {
-	if _, err := db.Exec(`
-		ALTER TABLE repositories ADD COLUMN IF NOT EXISTS proposed_manifest JSONB;
-	`); err != nil {
+	if err := runSchemaMigrations(); err != nil {
+		return err
+	}
+
+	enableEmbeddings()
+
+	if err := normalizeRepositoryNames(); err != nil {
+		return err
+	}
+
+	if err := backfillManifestEnv(); err != nil {
 		return err
 	}
 
@@ -164,7 +446,7 @@ func applyMigrations() error {
 	`
 	rows, err := db.Query(query)
 	if err != nil {
-		log.Fatalf("Error querying repositories: %v", err)
+		return fmt.Errorf("error querying repositories: %w", err)
 	}
 	defer rows.Close()
 
@@ -206,17 +488,197 @@ func applyMigrations() error {
 			if err != nil {
 				return err
 			}
-			log.Printf("Updated repository %d with new categories: %v", id, newCategories)
+			slog.Info("Updated repository with new categories", "id", id, "categories", newCategories)
 		}
 	}
 
-	return nil
+	return rows.Err()
+}
+
+// backfillManifestEnv populates manifest_env from the stored manifests the first time the
+// table is created. Afterwards it is kept in sync whenever a manifest is written.
+func backfillManifestEnv() error {
+	var populated bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM manifest_env)`).Scan(&populated); err != nil {
+		return err
+	}
+	if populated {
+		return nil
+	}
+
+	rows, err := db.Query(`
+		SELECT id, manifest::text
+		FROM repositories
+		WHERE manifest IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	manifests := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var manifest string
+		if err := rows.Scan(&id, &manifest); err != nil {
+			rows.Close()
+			return err
+		}
+		manifests[id] = manifest
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, manifest := range manifests {
+		if err := utils.SyncManifestEnv(tx, id, manifest); err != nil {
+			slog.Warn("Skipping env vars of repository with unparseable manifest", "id", id, "error", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// mergedJSONColumns and mergedTextColumns are the columns a duplicate row fills in on the row it
+// is merged into, when that row has nothing stored in them.
+var (
+	mergedJSONColumns = []string{"manifest", "proposed_manifest", "staging_manifest", "tool_definitions", "metadata"}
+	mergedTextColumns = []string{"preferred_key", "analyzed_sha", "readme_sha", "license", "icon", "owner_icon"}
+)
+
+// normalizeRepositoryNames rewrites stored full_name and path values into their normalized form,
+// merging rows that only differed by casing or slashes. The row with a manifest (or else the
+// oldest row) is kept; it takes over what only the duplicates had stored and their audit history.
+func normalizeRepositoryNames() error {
+	rows, err := db.Query(`
+		SELECT id, full_name, COALESCE(path, ''), COALESCE(manifest::text, '') NOT IN ('', '{}', 'null', '[]')
+		FROM repositories
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+
+	type repoName struct {
+		id          int
+		fullName    string
+		path        string
+		hasManifest bool
+	}
+	groups := make(map[string][]repoName)
+	var order []string
+	for rows.Next() {
+		var repo repoName
+		if err := rows.Scan(&repo.id, &repo.fullName, &repo.path, &repo.hasManifest); err != nil {
+			rows.Close()
+			return err
+		}
+		key := utils.NormalizeFullName(repo.fullName)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], repo)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, fullName := range order {
+		group := groups[fullName]
+		keep := group[0]
+		for _, repo := range group[1:] {
+			if repo.hasManifest && !keep.hasManifest {
+				keep = repo
+			}
+		}
+
+		path := utils.NormalizePath(keep.path)
+		if len(group) == 1 && keep.fullName == fullName && keep.path == path {
+			continue
+		}
+
+		for _, repo := range group {
+			if repo.id == keep.id {
+				continue
+			}
+			merged, err := mergeDuplicateRepository(tx, keep.id, repo.id)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM repositories WHERE id = $1`, repo.id); err != nil {
+				return err
+			}
+			slog.Info("Merged duplicate repository", "id", repo.id, "repo", repo.fullName, "into", keep.id, "merged", merged)
+		}
+
+		if _, err := tx.Exec(`UPDATE repositories SET full_name = $1, path = $2 WHERE id = $3`, fullName, path, keep.id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// mergeDuplicateRepository copies the columns the row keepID has nothing stored in from the
+// duplicate row duplicateID, along with the manifest_env rows of a copied manifest, and moves the
+// duplicate's audit history to keepID. It returns the columns that were copied.
+func mergeDuplicateRepository(tx *sql.Tx, keepID, duplicateID int) ([]string, error) {
+	var merged []string
+	copyColumn := func(column, empty string) error {
+		result, err := tx.Exec(fmt.Sprintf(`
+			UPDATE repositories
+			SET %[1]s = (SELECT source.%[1]s FROM repositories source WHERE source.id = $2)
+			WHERE id = $1 AND COALESCE(%[1]s::text, '') IN (%[2]s)
+				AND (SELECT COALESCE(source.%[1]s::text, '') FROM repositories source WHERE source.id = $2) NOT IN (%[2]s)
+		`, column, empty), keepID, duplicateID)
+		if err != nil {
+			return fmt.Errorf("error merging %s of repository %d into %d: %v", column, duplicateID, keepID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			merged = append(merged, column)
+		}
+		return nil
+	}
+	for _, column := range mergedJSONColumns {
+		if err := copyColumn(column, `'', '{}', 'null', '[]'`); err != nil {
+			return nil, err
+		}
+	}
+	for _, column := range mergedTextColumns {
+		if err := copyColumn(column, `''`); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(merged, "manifest") {
+		if _, err := tx.Exec(`DELETE FROM manifest_env WHERE repo_id = $1`, keepID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE manifest_env SET repo_id = $1 WHERE repo_id = $2`, keepID, duplicateID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE repository_audit SET repo_id = $1 WHERE repo_id = $2`, keepID, duplicateID); err != nil {
+		return nil, err
+	}
+	return merged, nil
 }
 
-func initGitHubClient() {
+func initGitHubClient() error {
 	token := os.Getenv("GITHUB_TOKEN")
 	if token == "" {
-		log.Fatalf("GITHUB_TOKEN environment variable is required")
+		return fmt.Errorf("GITHUB_TOKEN environment variable is required")
 	}
 
 	ctx := context.Background()
@@ -225,12 +687,40 @@ func initGitHubClient() {
 	)
 	tc := oauth2.NewClient(ctx, ts)
 	githubClient = github.NewClient(tc)
+	return nil
 }
 
-func initOpenAIClient() {
+func initOpenAIClient() error {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
-		log.Fatalf("OPENAI_API_KEY environment variable is required")
+		return fmt.Errorf("OPENAI_API_KEY environment variable is required")
+	}
+
+	config := openai.DefaultConfig(apiKey)
+	// Tag requests with the organization and project so usage is attributed correctly
+	config.OrgID = os.Getenv("OPENAI_ORG_ID")
+	if projectID := os.Getenv("OPENAI_PROJECT_ID"); projectID != "" {
+		config.HTTPClient = &http.Client{
+			Transport: &headerTransport{
+				base:   http.DefaultTransport,
+				header: "OpenAI-Project",
+				value:  projectID,
+			},
+		}
 	}
-	openaiClient = openai.NewClient(apiKey)
+	openaiClient = openai.NewClientWithConfig(config)
+	return nil
+}
+
+// headerTransport sets a fixed header on every outgoing request.
+type headerTransport struct {
+	base   http.RoundTripper
+	header string
+	value  string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.value)
+	return t.base.RoundTrip(req)
 }
@@ -0,0 +1,210 @@
+//go:build cgo
+
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestApplyMigrationsReturnsQueryErrors(t *testing.T) {
+	newTestDB(t)
+
+	// The category cleanup reads metadata after the schema migrations have run
+	if _, err := db.Exec(`ALTER TABLE repositories DROP COLUMN metadata`); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyMigrations(); err == nil {
+		t.Error("applyMigrations() succeeded without a metadata column")
+	}
+}
+
+func TestInitReadDB(t *testing.T) {
+	dbDriver, readDB = "sqlite3", nil
+	t.Cleanup(func() { readDB = nil })
+
+	t.Setenv("POSTGRES_READ_DSN", "")
+	if err := initReadDB(); err != nil || readDB != nil {
+		t.Errorf("initReadDB() without a DSN = %v, read database %v, want neither", err, readDB)
+	}
+
+	t.Setenv("POSTGRES_READ_DSN", "file:"+filepath.Join(t.TempDir(), "missing", "catalog.db"))
+	if err := initReadDB(); err == nil {
+		t.Error("initReadDB() succeeded for a database that can't be opened")
+	}
+	if readDB != nil {
+		t.Error("read database set after a failed open")
+	}
+}
+
+func TestNormalizeRepositoryNames(t *testing.T) {
+	newTestDB(t)
+
+	withoutManifest := insertTestRepo(t, "Owner/Repo", map[string]any{"path": "README.md"})
+	withManifest := insertTestRepo(t, "owner/repo/", map[string]any{"path": "/README.md", "manifest": `[{"command":"npx","args":["-y","server"]}]`})
+	oldest := insertTestRepo(t, "Other/Server", nil)
+	newer := insertTestRepo(t, "other//server", nil)
+	renamed := insertTestRepo(t, "Mono/Repo/Src//GitHub/", map[string]any{"path": "Src//GitHub/README.md"})
+	unchanged := insertTestRepo(t, "plain/server", map[string]any{"path": "README.md"})
+
+	if err := normalizeRepositoryNames(); err != nil {
+		t.Fatalf("normalizeRepositoryNames() error = %v", err)
+	}
+
+	rows, err := db.Query(`SELECT id, full_name, path FROM repositories ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := map[int]string{}
+	for rows.Next() {
+		var (
+			id             int
+			fullName, path string
+		)
+		if err := rows.Scan(&id, &fullName, &path); err != nil {
+			t.Fatal(err)
+		}
+		got[id] = fullName + " " + path
+	}
+
+	want := map[int]string{
+		// The duplicate with a manifest wins over the older one without
+		withManifest: "owner/repo README.md",
+		// Without manifests the oldest row is kept
+		oldest:    "other/server ",
+		renamed:   "mono/repo/Src/GitHub Src/GitHub/README.md",
+		unchanged: "plain/server README.md",
+	}
+	if len(got) != len(want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+	for id, name := range want {
+		if got[id] != name {
+			t.Errorf("row %d = %q, want %q", id, got[id], name)
+		}
+	}
+	for _, id := range []int{withoutManifest, newer} {
+		if _, ok := got[id]; ok {
+			t.Errorf("duplicate row %d was not merged", id)
+		}
+	}
+}
+
+func TestInitDBEmptySchema(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite3")
+	t.Setenv("SQLITE_DSN", "file:"+filepath.Join(t.TempDir(), "catalog.db")+"?_foreign_keys=on")
+	t.Setenv("OBOT_CATALOG_SERVER_ACCESS_TOKEN", "secret")
+	readDB = nil
+
+	if err := initDB(); err != nil {
+		t.Fatalf("initDB() error = %v", err)
+	}
+	first := db
+	// A second start finds every migration applied
+	if err := initDB(); err != nil {
+		t.Fatalf("initDB() on an initialized database error = %v", err)
+	}
+	t.Cleanup(func() {
+		first.Close()
+		db.Close()
+		db = nil
+	})
+
+	files, err := fs.Glob(migrationFiles, "migrations/sqlite3/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil || applied != len(files) {
+		t.Errorf("%d migrations recorded (%v), want %d", applied, err, len(files))
+	}
+
+	// The columns the handlers rely on exist on a brand-new database
+	id := insertTestRepo(t, "owner/repo", map[string]any{
+		"manifest":          `[{"command":"npx","args":["-y","old"]}]`,
+		"proposed_manifest": `[{"command":"npx","args":["-y","new"]}]`,
+	})
+	for _, tt := range []struct {
+		handler http.HandlerFunc
+		method  string
+		target  string
+	}{
+		{getRepoHandler, http.MethodGet, "/api/repos/" + strconv.Itoa(id)},
+		{approveRepoHandler, http.MethodPost, "/api/repos/" + strconv.Itoa(id) + "/approve"},
+	} {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		r.SetPathValue("id", strconv.Itoa(id))
+		r.AddCookie(&http.Cookie{Name: "obot-catalog-server-token", Value: "secret"})
+		w := httptest.NewRecorder()
+		tt.handler(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s = %d %s", tt.method, tt.target, w.Code, strings.TrimSpace(w.Body.String()))
+		}
+	}
+
+	var manifest string
+	if err := db.QueryRow(`SELECT manifest FROM repositories WHERE id = $1`, id).Scan(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(manifest, "new") {
+		t.Errorf("manifest after approval = %s, want the proposed one", manifest)
+	}
+}
+
+func TestNormalizeRepositoryNamesMergesDuplicates(t *testing.T) {
+	newTestDB(t)
+
+	const (
+		manifest = `[{"command":"npx","args":["-y","server"]}]`
+		metadata = `{"categories":"Databases"}`
+		tools    = `[{"name":"search"}]`
+	)
+	// The older row without a manifest is deleted, but it holds data the kept row lacks
+	duplicate := insertTestRepo(t, "Owner/Repo", map[string]any{
+		"metadata":         metadata,
+		"tool_definitions": tools,
+		"preferred_key":    "npx -y server",
+		"license":          "Apache-2.0",
+	})
+	keep := insertTestRepo(t, "owner/repo", map[string]any{"manifest": manifest, "metadata": "{}", "license": "MIT"})
+	for _, id := range []int{keep, duplicate} {
+		if _, err := db.Exec(`INSERT INTO repository_audit (repo_id, action, new_value) VALUES ($1, 'approve', '')`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := normalizeRepositoryNames(); err != nil {
+		t.Fatalf("normalizeRepositoryNames() error = %v", err)
+	}
+
+	var id int
+	var storedManifest, storedMetadata, storedTools, key, license string
+	if err := db.QueryRow(`SELECT id, manifest, metadata, COALESCE(tool_definitions, ''), COALESCE(preferred_key, ''), COALESCE(license, '') FROM repositories`).
+		Scan(&id, &storedManifest, &storedMetadata, &storedTools, &key, &license); err != nil {
+		t.Fatal(err)
+	}
+	if id != keep {
+		t.Fatalf("kept row %d, want %d", id, keep)
+	}
+	if storedManifest != manifest || storedMetadata != metadata || storedTools != tools || key != "npx -y server" {
+		t.Errorf("kept row = %s %s %s %q, want the duplicate's data merged in", storedManifest, storedMetadata, storedTools, key)
+	}
+	// Values the kept row already has are not overwritten
+	if license != "MIT" {
+		t.Errorf("license = %q, want MIT", license)
+	}
+
+	var auditRows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM repository_audit WHERE repo_id = $1`, keep).Scan(&auditRows); err != nil {
+		t.Fatal(err)
+	}
+	if auditRows != 2 {
+		t.Errorf("audit rows of the kept row = %d, want 2", auditRows)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build cgo
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverName is a SQLite driver that accepts the Postgres dialect the queries are written
+// in. It is meant for local development. Everything but embeddings works: without pgvector,
+// semantic search falls back to substring search and related repositories to shared categories.
+const sqliteDriverName = "sqlite3-catalog"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{
+		SQLiteDriver: sqlite3.SQLiteDriver{ConnectHook: registerSQLiteFunctions},
+	})
+}
+
+var (
+	// $1 style placeholders are named parameters in SQLite and get numbered by first appearance;
+	// ?1 keeps the index of the argument.
+	postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+	postgresCast        = regexp.MustCompile(`::(jsonb|json|text|boolean|integer)\b`)
+	ilikeKeyword        = regexp.MustCompile(`\bILIKE\b`)
+	forUpdateClause     = regexp.MustCompile(`\bFOR UPDATE\b`)
+)
+
+// toSQLite rewrites a Postgres query into the equivalent SQLite one. LIKE is case insensitive
+// for ASCII in SQLite and writes are serialized, so ILIKE and FOR UPDATE need no replacement.
+func toSQLite(query string) string {
+	query = postgresPlaceholder.ReplaceAllString(query, "?$1")
+	query = postgresCast.ReplaceAllString(query, "")
+	query = ilikeKeyword.ReplaceAllString(query, "LIKE")
+	return forUpdateClause.ReplaceAllString(query, "")
+}
+
+func registerSQLiteFunctions(conn *sqlite3.SQLiteConn) error {
+	err := conn.RegisterFunc("split_part", func(value, delimiter string, field int) string {
+		parts := strings.Split(value, delimiter)
+		if field < 1 || field > len(parts) {
+			return ""
+		}
+		return parts[field-1]
+	}, true)
+	if err != nil {
+		return err
+	}
+	return conn.RegisterAggregator("bool_or", newBoolOr, true)
+}
+
+// boolOr is the bool_or aggregate: true if any input is true.
+type boolOr struct {
+	result bool
+}
+
+func newBoolOr() *boolOr {
+	return &boolOr{}
+}
+
+func (b *boolOr) Step(value bool) {
+	b.result = b.result || value
+}
+
+func (b *boolOr) Done() bool {
+	return b.result
+}
+
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// sqliteConn rewrites every statement before handing it to SQLite.
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.SQLiteConn.Prepare(toSQLite(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.SQLiteConn.PrepareContext(ctx, toSQLite(query))
+}
+
+func (c *sqliteConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return c.SQLiteConn.Exec(toSQLite(query), args)
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, toSQLite(query), args)
+}
+
+func (c *sqliteConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.SQLiteConn.Query(toSQLite(query), args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, toSQLite(query), args)
+}
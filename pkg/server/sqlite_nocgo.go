@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package server
+
+// Without cgo the SQLite driver is a stub whose Open reports that cgo is required.
+const sqliteDriverName = "sqlite3"
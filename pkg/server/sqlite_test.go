@@ -0,0 +1,119 @@
+//go:build cgo
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// getList calls handler and decodes the JSON array it writes.
+func getList[T any](t *testing.T, handler http.HandlerFunc, r *http.Request) []T {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s %s: status %d: %s", r.Method, r.URL, w.Code, w.Body.String())
+	}
+	var items []T
+	if err := json.Unmarshal(w.Body.Bytes(), &items); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+	return items
+}
+
+func TestEnvVarsHandlerSQLite(t *testing.T) {
+	newTestDB(t)
+	envVarsCache.expires = time.Time{}
+
+	for i, env := range []struct {
+		key, name string
+		sensitive bool
+	}{
+		{"API_KEY", "api key", false},
+		{"API_KEY", "api key", true},
+		{"API_KEY", "", false},
+		{"API_KEY", "key", false},
+		{"DEBUG", "", false},
+	} {
+		id := insertTestRepo(t, "owner/repo-"+string(rune('a'+i)), nil)
+		if _, err := db.Exec(`INSERT INTO manifest_env (repo_id, key, name, sensitive) VALUES ($1, $2, $3, $4)`,
+			id, env.key, env.name, env.sensitive); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := getList[types.EnvVarUsage](t, getEnvVarsHandler, httptest.NewRequest(http.MethodGet, "/api/env-vars", nil))
+	want := []types.EnvVarUsage{
+		{Key: "API_KEY", Name: "api key", Count: 4, Sensitive: true},
+		{Key: "DEBUG", Name: "", Count: 1, Sensitive: false},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("env vars = %+v, want %+v", got, want)
+	}
+}
+
+func TestOwnersHandlerSQLite(t *testing.T) {
+	newTestDB(t)
+	ownersCache.expires = time.Time{}
+
+	insertTestRepo(t, "acme/one", map[string]any{"stars": 10, "metadata": `{"categories":"Databases"}`})
+	insertTestRepo(t, "acme/two", map[string]any{"stars": 5, "metadata": `{"categories":"Developer Tools,Databases"}`})
+	insertTestRepo(t, "solo/server", map[string]any{"stars": 100, "metadata": `{}`})
+
+	got := getList[types.OwnerStats](t, getOwnersHandler, httptest.NewRequest(http.MethodGet, "/api/owners", nil))
+	if len(got) != 2 {
+		t.Fatalf("got %d owners, want 2: %+v", len(got), got)
+	}
+	if got[0].Owner != "acme" || got[0].Servers != 2 || got[0].Stars != 15 {
+		t.Errorf("first owner = %+v, want acme with 2 servers and 15 stars", got[0])
+	}
+	if !slices.Equal(got[0].Categories, []string{"Databases", "Developer Tools"}) {
+		t.Errorf("acme categories = %q", got[0].Categories)
+	}
+	if got[1].Owner != "solo" || len(got[1].Categories) != 0 {
+		t.Errorf("second owner = %+v, want solo without categories", got[1])
+	}
+}
+
+func TestRelatedReposHandlerSQLite(t *testing.T) {
+	newTestDB(t)
+
+	id := insertTestRepo(t, "owner/source", map[string]any{"language": "Go", "metadata": `{"categories":"Developer Tools,Databases,Featured"}`})
+	insertTestRepo(t, "owner/both", map[string]any{"language": "Python", "stars": 1, "metadata": `{"categories":"Databases,Developer Tools"}`})
+	insertTestRepo(t, "owner/one", map[string]any{"language": "Python", "stars": 100, "metadata": `{"categories":"Databases"}`})
+	insertTestRepo(t, "owner/language", map[string]any{"language": "go", "stars": 5, "metadata": `{}`})
+	insertTestRepo(t, "owner/flag", map[string]any{"language": "Rust", "stars": 50, "metadata": `{"categories":"Featured"}`})
+	insertTestRepo(t, "owner/prefix", map[string]any{"language": "Rust", "stars": 50, "metadata": `{"categories":"Developer Tools Extra"}`})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/repos/"+strconv.Itoa(id)+"/related", nil)
+	r.SetPathValue("id", strconv.Itoa(id))
+	var got []string
+	for _, repo := range getList[types.RepoInfo](t, getRelatedReposHandler, r) {
+		got = append(got, repo.FullName)
+	}
+	want := []string{"owner/both", "owner/one", "owner/language"}
+	if !slices.Equal(got, want) {
+		t.Errorf("related = %q, want %q", got, want)
+	}
+}
+
+func TestSemanticSearchFallsBackSQLite(t *testing.T) {
+	newTestDB(t)
+
+	insertTestRepo(t, "owner/postgres-server", map[string]any{"description": "Query Postgres"})
+	insertTestRepo(t, "owner/slack-server", map[string]any{"description": "Send messages"})
+
+	got := getList[types.RepoInfo](t, semanticSearchHandler, httptest.NewRequest(http.MethodGet, "/api/semantic-search?q=postgres", nil))
+	if len(got) != 1 || got[0].FullName != "owner/postgres-server" {
+		t.Errorf("results = %+v, want owner/postgres-server", got)
+	}
+}
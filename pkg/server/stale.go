@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultStaleAge = 30 * 24 * time.Hour
+
+// getStaleReposHandler lists the repositories the scraper hasn't saved or confirmed within
+// ?olderThan= (a Go duration, default 720h), least recently scraped first. Repositories that were
+// never scraped since last_scraped_at was introduced come first.
+func getStaleReposHandler(w http.ResponseWriter, r *http.Request) {
+	olderThan := defaultStaleAge
+	if raw := r.URL.Query().Get("olderThan"); raw != "" {
+		var err error
+		if olderThan, err = time.ParseDuration(raw); err != nil || olderThan <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid olderThan %q: must be a positive duration such as 720h", raw), http.StatusBadRequest)
+			return
+		}
+	}
+	limit, offset := parsePagination(r)
+	cutoff := time.Now().Add(-olderThan).UTC()
+
+	condition := "(last_scraped_at IS NULL OR last_scraped_at < $1)"
+	if r.URL.Query().Get("includeDeprecated") != "true" {
+		condition += " AND NOT deprecated"
+	}
+
+	var totalCount int
+	if err := reader().QueryRow(`SELECT COUNT(*) FROM repositories WHERE `+condition, cutoff).Scan(&totalCount); err != nil {
+		http.Error(w, fmt.Sprintf("Error counting stale repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := reader().Query(`
+		SELECT `+repoSummaryColumns+`
+		FROM repositories
+		WHERE `+condition+`
+		ORDER BY last_scraped_at NULLS FIRST, full_name
+		LIMIT $2 OFFSET $3
+	`, cutoff, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error querying stale repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	repos, err := scanRepoSummaries(rows)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading stale repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, repos, totalCount)
+}
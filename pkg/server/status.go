@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+var (
+	scrapeStatusMu sync.Mutex
+	scrapeStatus   types.ScrapeStatus
+)
+
+// updateScrapeStatus applies fn to the status of the current scrape while holding the lock.
+func updateScrapeStatus(fn func(status *types.ScrapeStatus)) {
+	scrapeStatusMu.Lock()
+	defer scrapeStatusMu.Unlock()
+	fn(&scrapeStatus)
+}
+
+// Reasons a repository found by the scraper is not analyzed.
+const (
+	skipReadmeUnchanged = "readme_unchanged"
+	skipCommitUnchanged = "commit_unchanged"
+	skipNoMCPCommand    = "no_mcp_command"
+	skipAnalysisEmpty   = "analysis_empty"
+)
+
+// recordSkip counts a repository skipped for reason, in the metrics and in the status of the
+// running scrape.
+func recordSkip(reason string) {
+	utils.ReposSkipped.WithLabelValues(reason).Inc()
+	updateScrapeStatus(func(status *types.ScrapeStatus) {
+		if status.Running {
+			status.Skipped[reason]++
+		}
+	})
+}
+
+// skipReason returns the skip reason an error from AddRepo stands for, or "" for real failures.
+func skipReason(err error) string {
+	switch {
+	case errors.Is(err, errNoMCPCommand):
+		return skipNoMCPCommand
+	case errors.Is(err, utils.ErrNoMCPServer):
+		return skipAnalysisEmpty
+	}
+	return ""
+}
+
+func getScrapeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	scrapeStatusMu.Lock()
+	status := scrapeStatus
+	status.Skipped = maps.Clone(scrapeStatus.Skipped)
+	scrapeStatusMu.Unlock()
+
+	writeJSON(w, r, status)
+}
+
+// tokenBudget caps the OpenAI tokens a single scrape may spend, configured with MAX_TOKENS_PER_RUN.
+// A limit of zero means the scrape is unlimited. Only the calls made with a context from
+// withContext count against it.
+type tokenBudget struct {
+	limit  int64
+	tokens utils.TokenCounter
+	logged sync.Once
+}
+
+func newTokenBudget() *tokenBudget {
+	limit, _ := strconv.ParseInt(os.Getenv("MAX_TOKENS_PER_RUN"), 10, 64)
+	return &tokenBudget{limit: max(limit, 0)}
+}
+
+// withContext returns a copy of ctx whose OpenAI calls are charged to the budget.
+func (b *tokenBudget) withContext(ctx context.Context) context.Context {
+	return utils.WithTokenCounter(ctx, &b.tokens)
+}
+
+// used returns the tokens charged to the budget so far.
+func (b *tokenBudget) used() int64 {
+	return b.tokens.Load()
+}
+
+// exhausted reports whether no new analyses should be started. Work already in flight is
+// allowed to finish, so the final spend can exceed the limit slightly.
+func (b *tokenBudget) exhausted() bool {
+	if b.limit == 0 || b.used() < b.limit {
+		return false
+	}
+	b.logged.Do(func() {
+		slog.Warn("Token budget reached, not starting any more analyses in this scrape", "budget", b.limit, "used", b.used())
+	})
+	return true
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/obot-platform/catalog-service/pkg/utils"
+)
+
+const (
+	verifyTimeout         = 20 * time.Second
+	mcpProtocolVersion    = "2025-03-26"
+	maxVerifyResponseSize = 4 << 20
+)
+
+// healthReport is the result of a verification handshake.
+type healthReport struct {
+	Started         bool   `json:"started"`
+	ProtocolVersion string `json:"protocolVersion,omitempty"`
+	ServerName      string `json:"serverName,omitempty"`
+	ServerVersion   string `json:"serverVersion,omitempty"`
+	ToolCount       int    `json:"toolCount"`
+	Error           string `json:"error,omitempty"`
+}
+
+// verifyRepoHandler checks that a server actually works by running the MCP initialize and
+// tools/list handshake against its preferred config (or the ?config= index). Header values the
+// server needs are taken from the request body, {"headers": {"KEY": "value"}}. Nothing is stored.
+//
+// The service never executes manifest commands, so only remote (url) configs can be verified;
+// for command configs the report says so.
+func verifyRepoHandler(w http.ResponseWriter, r *http.Request) {
+	if !utils.IsAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	repoID, ok := parseRepoID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Headers map[string]string `json:"headers"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var manifest string
+	err := reader().QueryRow(`SELECT COALESCE(manifest::text, '') FROM repositories WHERE id = $1`, repoID).Scan(&manifest)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching repository: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs, err := utils.ParseManifest(manifest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing stored manifest: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	if len(configs) == 0 {
+		http.Error(w, "Repository has no manifest", http.StatusNotFound)
+		return
+	}
+
+	config, _ := utils.PreferredConfig(configs)
+	if raw := r.URL.Query().Get("config"); raw != "" {
+		index, err := strconv.Atoi(raw)
+		if err != nil || index < 0 || index >= len(configs) {
+			http.Error(w, fmt.Sprintf("Invalid config %q: must be an index between 0 and %d", raw, len(configs)-1), http.StatusBadRequest)
+			return
+		}
+		config = configs[index]
+	}
+
+	var report healthReport
+	if config.URL == "" {
+		report.Error = "only remote servers can be verified; command configs are not executed by the catalog"
+	} else if missing := missingRequired(config.HTTPHeaders, input.Headers); len(missing) > 0 {
+		report.Error = "missing required headers: " + strings.Join(missing, ", ")
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), verifyTimeout)
+		defer cancel()
+		report = verifyRemoteServer(ctx, config.URL, input.Headers)
+	}
+
+	requestLogger(r).Info("Verified repository", "id", repoID, "started", report.Started, "tools", report.ToolCount, "error", report.Error)
+	writeJSON(w, r, report)
+}
+
+// missingRequired returns the keys of the required pairs that have no value in values.
+func missingRequired(pairs []types.MCPPair, values map[string]string) []string {
+	var missing []string
+	for _, pair := range pairs {
+		if pair.Required && strings.TrimSpace(values[pair.Key]) == "" {
+			missing = append(missing, pair.Key)
+		}
+	}
+	return missing
+}
+
+// verifyRemoteServer runs the handshake against a server using the streamable HTTP transport.
+func verifyRemoteServer(ctx context.Context, url string, headers map[string]string) healthReport {
+	var report healthReport
+	session := &mcpSession{url: url, headers: headers}
+
+	var initialized struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	err := session.call(ctx, 1, "initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "obot-catalog-verify", "version": "1.0.0"},
+	}, &initialized)
+	if err != nil {
+		report.Error = fmt.Sprintf("initialize failed: %v", err)
+		return report
+	}
+	report.Started = true
+	report.ProtocolVersion = initialized.ProtocolVersion
+	report.ServerName = initialized.ServerInfo.Name
+	report.ServerVersion = initialized.ServerInfo.Version
+
+	if err := session.call(ctx, 0, "notifications/initialized", nil, nil); err != nil {
+		report.Error = fmt.Sprintf("initialized notification failed: %v", err)
+		return report
+	}
+
+	var tools struct {
+		Tools []json.RawMessage `json:"tools"`
+	}
+	if err := session.call(ctx, 2, "tools/list", map[string]any{}, &tools); err != nil {
+		report.Error = fmt.Sprintf("tools/list failed: %v", err)
+		return report
+	}
+	report.ToolCount = len(tools.Tools)
+	return report
+}
+
+// mcpSession is a minimal JSON-RPC client for the MCP streamable HTTP transport.
+type mcpSession struct {
+	url       string
+	headers   map[string]string
+	sessionID string
+}
+
+// call sends a request, or a notification when id is 0, and decodes the result into result.
+func (s *mcpSession) call(ctx context.Context, id int, method string, params, result any) error {
+	message := map[string]any{"jsonrpc": "2.0", "method": method}
+	if id != 0 {
+		message["id"] = id
+	}
+	if params != nil {
+		message["params"] = params
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if s.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", s.sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("server responded with %s", resp.Status)
+	}
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		s.sessionID = sessionID
+	}
+	if id == 0 {
+		return nil
+	}
+
+	payload, err := readRPCResponse(resp)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return fmt.Errorf("invalid JSON-RPC response: %v", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("error %d: %s", response.Error.Code, response.Error.Message)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// readRPCResponse returns the JSON-RPC response from a plain JSON body or from the first event of
+// an event stream that is a response rather than a server notification.
+func readRPCResponse(resp *http.Response) ([]byte, error) {
+	body := io.LimitReader(resp.Body, maxVerifyResponseSize)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return io.ReadAll(body)
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxVerifyResponseSize)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimSpace(value))
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			continue
+		}
+
+		event := []byte(strings.Join(data, "\n"))
+		data = nil
+		var message struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(event, &message) == nil && len(message.ID) > 0 {
+			return event, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Platforms lists the operating systems a server is documented to support. It is stored as a
+// comma separated string; an empty list means the README doesn't restrict the platform.
+type Platforms []string
+
+// Scan implements sql.Scanner.
+func (p *Platforms) Scan(src any) error {
+	var value string
+	switch src := src.(type) {
+	case nil:
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into Platforms", src)
+	}
+
+	*p = nil
+	for _, platform := range strings.Split(value, ",") {
+		if platform = strings.TrimSpace(platform); platform != "" {
+			*p = append(*p, platform)
+		}
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (p Platforms) Value() (driver.Value, error) {
+	return strings.Join(p, ","), nil
+}
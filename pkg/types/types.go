@@ -1,5 +1,28 @@
 package types
 
+import "time"
+
+// Categories is the list of categories the analyzer may assign to a repository. It can be
+// replaced per deployment through the CATEGORIES or CATEGORIES_FILE environment variables.
+var Categories = []string{
+	"Databases",
+	"Data & Analytics",
+	"File & Storage Systems",
+	"Retrieval & Search",
+	"SaaS & API Integrations",
+	"Communication & Messaging",
+	"Automation & Browsers",
+	"Time & Scheduling",
+	"Maps & Location",
+	"Media & Design",
+	"Memory & Reasoning",
+	"Developer Tools",
+	"Monitoring & Observability",
+	"Infrastructure & DevOps",
+	"Science & Research",
+	"Finance & Commerce",
+}
+
 // RepoInfo stores information about a repository
 type RepoInfo struct {
 	ID               int    `json:"id"`
@@ -14,9 +37,96 @@ type RepoInfo struct {
 	Metadata         string `json:"metadata"`
 	License          string `json:"license"`
 	Icon             string `json:"icon"`
+	OwnerIcon        string `json:"ownerIcon,omitempty"`
 	Manifest         string `json:"manifest"`
 	ProposedManifest string `json:"proposedManifest"`
+	StagingManifest  string `json:"stagingManifest,omitempty"`
 	ToolDefinitions  string `json:"toolDefinitions"`
+	Version          int    `json:"version"`
+	AnalyzedSHA      string `json:"analyzedSha,omitempty"`
+	ReadmeSHA        string `json:"readmeSha,omitempty"`
+	Deprecated       bool   `json:"deprecated"`
+	SupersededBy     string `json:"supersededBy,omitempty"`
+	// PreferredKey identifies the config a curator chose as preferred; empty when the command
+	// order decides.
+	PreferredKey string `json:"preferredKey,omitempty"`
+	// Platforms is empty unless the README restricts the operating systems the server runs on.
+	Platforms Platforms `json:"platforms,omitempty"`
+	// LastScrapedAt is when the scraper last saved or confirmed the repository, nil if it never has.
+	LastScrapedAt *time.Time `json:"lastScrapedAt,omitempty"`
+	// CreatedAt is included in exports and repository summaries, UpdatedAt only in exports.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+
+	// Requirements is computed from the preferred config of the manifest and is not stored.
+	Requirements *ConfigRequirements `json:"requirements,omitempty"`
+	// Subpath is the directory of the server inside a monorepo. Stars, license and the owner icon
+	// of a server with a subpath belong to the whole repository, which RepoLevelStats indicates.
+	Subpath        string `json:"subpath,omitempty"`
+	RepoLevelStats bool   `json:"repoLevelStats,omitempty"`
+}
+
+// ConfigRequirements splits what a user must provide to start a server from what is optional.
+type ConfigRequirements struct {
+	RequiredEnv     []MCPPair `json:"requiredEnv"`
+	OptionalEnv     []MCPPair `json:"optionalEnv"`
+	RequiredHeaders []MCPPair `json:"requiredHeaders,omitempty"`
+	OptionalHeaders []MCPPair `json:"optionalHeaders,omitempty"`
+}
+
+// AuditEntry records a single change made to a repository.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	RepoID    int       `json:"repoId"`
+	Action    string    `json:"action"`
+	OldValue  string    `json:"oldValue"`
+	NewValue  string    `json:"newValue"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AnalysisFailure tracks a repository whose analysis repeatedly produced no manifest.
+type AnalysisFailure struct {
+	FullName          string    `json:"fullName"`
+	URL               string    `json:"url"`
+	AnalysisAttempts  int       `json:"analysisAttempts"`
+	LastAnalysisError string    `json:"lastAnalysisError"`
+	LastAttemptAt     time.Time `json:"lastAttemptAt"`
+}
+
+// EnvVarUsage counts the repositories whose manifest uses an env var.
+type EnvVarUsage struct {
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+	Sensitive bool   `json:"sensitive"`
+}
+
+// OwnerStats summarizes the servers a GitHub owner publishes in the catalog.
+type OwnerStats struct {
+	Owner      string   `json:"owner"`
+	Icon       string   `json:"icon,omitempty"`
+	Servers    int      `json:"servers"`
+	Stars      int      `json:"stars"`
+	Categories []string `json:"categories"`
+}
+
+// ScrapeStatus summarizes the current or most recent scrape.
+type ScrapeStatus struct {
+	Running          bool       `json:"running"`
+	Mode             string     `json:"mode,omitempty"`
+	Force            bool       `json:"force"`
+	StartedAt        time.Time  `json:"startedAt"`
+	FinishedAt       *time.Time `json:"finishedAt,omitempty"`
+	Found            int        `json:"found"`
+	Processed        int        `json:"processed"`
+	TokensUsed       int64      `json:"tokensUsed"`
+	TokenBudget      int64      `json:"tokenBudget,omitempty"`
+	SkippedForBudget int        `json:"skippedForBudget"`
+	// Pending counts the repositories a sampled scrape left for later scrapes.
+	Pending int `json:"pending,omitempty"`
+	// Skipped counts the repositories that were not (re)analyzed, by reason.
+	Skipped map[string]int `json:"skipped"`
+	Error   string         `json:"error,omitempty"`
 }
 
 type MCPServerManifest struct {
@@ -24,6 +134,12 @@ type MCPServerManifest struct {
 	Description string            `json:"description"`
 	Category    string            `json:"category"`
 	Configs     []MCPServerConfig `json:"configs"`
+	// Deprecated is set when the README says the server is deprecated or unmaintained, and
+	// SupersededBy points to its replacement if the README names one.
+	Deprecated   bool   `json:"deprecated,omitempty"`
+	SupersededBy string `json:"supersededBy,omitempty"`
+	// Platforms lists the operating systems the README says the server supports, if it says.
+	Platforms []string `json:"platforms,omitempty"`
 }
 
 type Config struct {
@@ -38,6 +154,8 @@ type MCPServerConfig struct {
 	URL            string    `json:"url,omitempty"`
 	URLDescription string    `json:"urlDescription,omitempty"`
 	Preferred      bool      `json:"preferred,omitempty"`
+	// SourceSnippet is the README excerpt the config was extracted from, for reviewers.
+	SourceSnippet string `json:"sourceSnippet,omitempty"`
 }
 
 type MCPPair struct {
@@ -60,6 +178,12 @@ type MCPTool struct {
 	InputSchema InputSchema `json:"inputSchema,omitempty"`
 }
 
+// SkippedTool is a tool that could not be converted to another format.
+type SkippedTool struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
 type InputSchema struct {
 	Properties map[string]Property `json:"properties"`
 }
@@ -69,3 +193,36 @@ type Property struct {
 	Description string `json:"description"`
 	Required    bool   `json:"required"`
 }
+
+// SmitheryServer is a server in the interchange format used by the Smithery registry.
+type SmitheryServer struct {
+	QualifiedName string               `json:"qualifiedName"`
+	DisplayName   string               `json:"displayName"`
+	Description   string               `json:"description"`
+	Homepage      string               `json:"homepage,omitempty"`
+	IconURL       string               `json:"iconUrl,omitempty"`
+	Connections   []SmitheryConnection `json:"connections"`
+}
+
+// SmitheryConnection is one way to connect to a Smithery server: a local stdio command or a
+// remote http deployment.
+type SmitheryConnection struct {
+	Type          string               `json:"type"`
+	Command       string               `json:"command,omitempty"`
+	Args          []string             `json:"args,omitempty"`
+	DeploymentURL string               `json:"deploymentUrl,omitempty"`
+	ConfigSchema  SmitheryConfigSchema `json:"configSchema"`
+}
+
+// SmitheryConfigSchema is the JSON schema of the configuration a connection needs.
+type SmitheryConfigSchema struct {
+	Type       string                      `json:"type"`
+	Required   []string                    `json:"required"`
+	Properties map[string]SmitheryProperty `json:"properties"`
+}
+
+type SmitheryProperty struct {
+	Type        string `json:"type"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// computedManifestFields are set by the service after the analysis, so the model isn't asked for them.
+var computedManifestFields = []string{"preferred"}
+
+// analysisResponseFormat constrains the analyzer's answer to the shape of types.MCPServerManifest
+// with OpenAI structured outputs.
+var analysisResponseFormat = sync.OnceValues(func() (*openai.ChatCompletionResponseFormat, error) {
+	schema, err := manifestSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   "mcp_server_manifest",
+			Schema: schema,
+			Strict: true,
+		},
+	}, nil
+})
+
+// batchResponseFormat constrains a batched answer to {"results": [...]}, holding one manifest per
+// repository with the name of the repository it is for.
+var batchResponseFormat = sync.OnceValues(func() (*openai.ChatCompletionResponseFormat, error) {
+	manifest, err := manifestSchema()
+	if err != nil {
+		return nil, err
+	}
+	manifest.Properties["repository"] = jsonschema.Definition{Type: jsonschema.String}
+	schema := &jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"results": {Type: jsonschema.Array, Items: manifest},
+		},
+	}
+	strictSchema(schema)
+
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   "mcp_server_manifests",
+			Schema: schema,
+			Strict: true,
+		},
+	}, nil
+})
+
+// manifestSchema returns the strict schema of types.MCPServerManifest.
+func manifestSchema() (*jsonschema.Definition, error) {
+	schema, err := jsonschema.GenerateSchemaForType(types.MCPServerManifest{})
+	if err != nil {
+		return nil, err
+	}
+	strictSchema(schema)
+	if platforms, ok := schema.Properties["platforms"]; ok && platforms.Items != nil {
+		platforms.Items.Enum = knownPlatforms
+	}
+	return schema, nil
+}
+
+// strictSchema adapts a generated schema to strict mode, which requires every property of an
+// object to be listed as required and no others to be allowed. Optional fields therefore come
+// back empty instead of missing, which decodes the same.
+func strictSchema(d *jsonschema.Definition) {
+	if d.Type == jsonschema.Object {
+		for _, name := range computedManifestFields {
+			delete(d.Properties, name)
+		}
+		for name, property := range d.Properties {
+			strictSchema(&property)
+			d.Properties[name] = property
+		}
+		d.Required = slices.Sorted(maps.Keys(d.Properties))
+		d.AdditionalProperties = false
+	}
+	if d.Items != nil {
+		strictSchema(d.Items)
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// checkStrictSchema reports every object in schema that OpenAI would reject in strict mode, along
+// with any property the service computes itself.
+func checkStrictSchema(t *testing.T, path string, schema map[string]any) {
+	t.Helper()
+
+	if schema["type"] == "object" {
+		properties, _ := schema["properties"].(map[string]any)
+		if _, ok := properties["preferred"]; ok {
+			t.Errorf("%s: asks the model for the computed preferred field", path)
+		}
+		var required []string
+		for _, name := range schema["required"].([]any) {
+			required = append(required, name.(string))
+		}
+		if want := slices.Sorted(maps.Keys(properties)); !slices.Equal(slices.Sorted(slices.Values(required)), want) {
+			t.Errorf("%s: required = %q, want all properties %q", path, required, want)
+		}
+		if additional, ok := schema["additionalProperties"]; !ok || additional != false {
+			t.Errorf("%s: additionalProperties = %v, want false", path, additional)
+		}
+		for name, property := range properties {
+			checkStrictSchema(t, path+"."+name, property.(map[string]any))
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		checkStrictSchema(t, path+"[]", items)
+	}
+}
+
+func TestAnalysisResponseFormatsAreStrict(t *testing.T) {
+	for name, responseFormat := range map[string]func() (*openai.ChatCompletionResponseFormat, error){
+		"single": analysisResponseFormat,
+		"batch":  batchResponseFormat,
+	} {
+		t.Run(name, func(t *testing.T) {
+			format, err := responseFormat()
+			if err != nil {
+				t.Fatalf("building the schema: %v", err)
+			}
+			if format.Type != openai.ChatCompletionResponseFormatTypeJSONSchema || !format.JSONSchema.Strict {
+				t.Errorf("response format = %s, strict %v, want a strict JSON schema", format.Type, format.JSONSchema.Strict)
+			}
+
+			encoded, err := json.Marshal(format.JSONSchema.Schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var schema map[string]any
+			if err := json.Unmarshal(encoded, &schema); err != nil {
+				t.Fatal(err)
+			}
+			checkStrictSchema(t, "$", schema)
+		})
+	}
+}
+
+func TestBatchResponseFormatNamesRepositories(t *testing.T) {
+	format, err := batchResponseFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(format.JSONSchema.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties struct {
+			Results struct {
+				Items struct {
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"items"`
+			} `json:"results"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(encoded, &schema); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"repository", "configs", "platforms"} {
+		if _, ok := schema.Properties.Results.Items.Properties[name]; !ok {
+			t.Errorf("batch results have no %s property", name)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/sashabaranov/go-openai"
+)
+
+// batchedAnalysis is one repository analyzed as part of a batch.
+type batchedAnalysis struct {
+	Repository string `json:"repository"`
+	types.MCPServerManifest
+}
+
+// AnalyzeBatchWithOpenAI analyzes several READMEs, keyed by repository name, in a single request.
+// Repositories missing from the response are missing from the result; callers should analyze
+// them on their own.
+func AnalyzeBatchWithOpenAI(ctx context.Context, openaiClient *openai.Client, readmes map[string]string) (map[string]types.MCPServerManifest, error) {
+	var repos strings.Builder
+	for repoName, readme := range readmes {
+		fmt.Fprintf(&repos, "=== REPOSITORY %s ===\n%s\n=== END REPOSITORY %s ===\n\n", repoName, readme, repoName)
+	}
+
+	prompt := fmt.Sprintf(`
+You are an expert in Model Context Protocol (MCP) servers. Analyze each of the following READMEs on its own. Every README starts with a line "=== REPOSITORY <name> ===" and ends with a line "=== END REPOSITORY <name> ===". Never mix information from different READMEs.
+
+%s
+For each repository:
+
+%s
+
+Respond with a JSON object of the form {"results": [...]} that contains exactly one OpenAIResponse per repository, each with an additional "repository" field set to the repository name exactly as given. For a repository without an MCP server, return an entry with the repository field set and every other field empty.
+`, repos.String(), analysisInstructions())
+
+	responseFormat, err := batchResponseFormat()
+	if err != nil {
+		return nil, fmt.Errorf("error building the analysis schema: %v", err)
+	}
+
+	resp, err := withOpenAIRetry(ctx, "analyze_batch", func() (openai.ChatCompletionResponse, error) {
+		return openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model: openai.GPT4Dot1,
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: prompt,
+				},
+			},
+			ResponseFormat: responseFormat,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API error: %v", err)
+	}
+	recordUsage(ctx, resp.Usage)
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from OpenAI")
+	}
+
+	var parsed struct {
+		Results []batchedAnalysis `json:"results"`
+	}
+	if err := unmarshalOpenAIJSON("analyze_batch", resp.Choices[0].Message.Content, &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAI response: %v", err)
+	}
+
+	results := make(map[string]types.MCPServerManifest, len(parsed.Results))
+	for _, result := range parsed.Results {
+		// Ignore names the model made up, and keep the first answer for names it repeated
+		if _, requested := readmes[result.Repository]; !requested {
+			continue
+		}
+		if _, seen := results[result.Repository]; !seen {
+			results[result.Repository] = result.MCPServerManifest
+		}
+	}
+	return results, nil
+}
+
+// analysisBatchConfig controls batching of small READMEs. ANALYSIS_BATCH_SIZE is the maximum
+// number of READMEs per request (default 1, which disables batching), ANALYSIS_BATCH_MAX_README
+// the largest README in characters that is batched (default 4000) and ANALYSIS_BATCH_WAIT how long
+// a README waits for others before it is sent anyway (default 2s).
+var analysisBatchConfig = sync.OnceValue(func() (config struct {
+	size      int
+	maxReadme int
+	wait      time.Duration
+}) {
+	config.size, config.maxReadme, config.wait = 1, 4000, 2*time.Second
+	if size, err := strconv.Atoi(os.Getenv("ANALYSIS_BATCH_SIZE")); err == nil && size > 0 {
+		config.size = size
+	}
+	if maxReadme, err := strconv.Atoi(os.Getenv("ANALYSIS_BATCH_MAX_README")); err == nil && maxReadme > 0 {
+		config.maxReadme = maxReadme
+	}
+	if wait, err := time.ParseDuration(os.Getenv("ANALYSIS_BATCH_WAIT")); err == nil && wait > 0 {
+		config.wait = wait
+	}
+	return config
+})
+
+type batchRequest struct {
+	ctx      context.Context
+	repoName string
+	readme   string
+	result   chan *types.MCPServerManifest
+}
+
+// analysisBatcher collects the small READMEs that concurrent scrape workers analyze and sends them
+// in batches.
+type analysisBatcher struct {
+	mu      sync.Mutex
+	pending []*batchRequest
+	timer   *time.Timer
+}
+
+var batcher = &analysisBatcher{}
+
+// analyzeReadme analyzes one README, batching it with others when batching is enabled and the
+// README is small. A README the batch didn't answer for is analyzed on its own.
+func analyzeReadme(ctx context.Context, openaiClient *openai.Client, repoName, readmeContent, existingConfig string) (types.MCPServerManifest, error) {
+	config := analysisBatchConfig()
+	if config.size <= 1 || len(readmeContent) > config.maxReadme {
+		return AnalyzeWithOpenAI(ctx, openaiClient, repoName, readmeContent, existingConfig)
+	}
+
+	request := &batchRequest{ctx: ctx, repoName: repoName, readme: readmeContent, result: make(chan *types.MCPServerManifest, 1)}
+	batcher.mu.Lock()
+	batcher.pending = append(batcher.pending, request)
+	if len(batcher.pending) >= config.size {
+		batch := batcher.take()
+		batcher.mu.Unlock()
+		go batcher.send(openaiClient, batch)
+	} else {
+		if batcher.timer == nil {
+			batcher.timer = time.AfterFunc(config.wait, func() {
+				batcher.mu.Lock()
+				batch := batcher.take()
+				batcher.mu.Unlock()
+				batcher.send(openaiClient, batch)
+			})
+		}
+		batcher.mu.Unlock()
+	}
+
+	select {
+	case result := <-request.result:
+		if result != nil {
+			return *result, nil
+		}
+	case <-ctx.Done():
+		return types.MCPServerManifest{}, ctx.Err()
+	}
+	return AnalyzeWithOpenAI(ctx, openaiClient, repoName, readmeContent, existingConfig)
+}
+
+// take removes the pending requests. The caller must hold mu.
+func (b *analysisBatcher) take() []*batchRequest {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// send analyzes a batch and hands every request its result, or nil when it has to be analyzed on
+// its own.
+func (b *analysisBatcher) send(openaiClient *openai.Client, batch []*batchRequest) {
+	if len(batch) == 0 {
+		return
+	}
+	if len(batch) == 1 {
+		batch[0].result <- nil
+		return
+	}
+
+	readmes := make(map[string]string, len(batch))
+	for _, request := range batch {
+		readmes[request.repoName] = request.readme
+	}
+	ctx, cancel := batchContext(batch)
+	defer cancel()
+	tokens := &TokenCounter{}
+	results, err := AnalyzeBatchWithOpenAI(WithTokenCounter(ctx, tokens), openaiClient, readmes)
+	// Every caller is charged its share of the request
+	for i, request := range batch {
+		share := tokens.Load() / int64(len(batch))
+		if i == 0 {
+			share += tokens.Load() % int64(len(batch))
+		}
+		addTokens(request.ctx, share)
+	}
+	if err != nil {
+		slog.Warn("Batched analysis failed, analyzing repositories one by one", "repos", len(batch), "error", err)
+	} else {
+		slog.Debug("Analyzed batch", "repos", len(batch), "answered", len(results))
+	}
+
+	for _, request := range batch {
+		if result, ok := results[request.repoName]; ok {
+			request.result <- &result
+		} else {
+			request.result <- nil
+		}
+	}
+}
+
+// batchContext returns a context for a request made on behalf of every request in batch. It is
+// cancelled once all of them are, so one caller giving up doesn't fail the others.
+func batchContext(batch []*batchRequest) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var remaining atomic.Int32
+	remaining.Store(int32(len(batch)))
+	stops := make([]func() bool, 0, len(batch))
+	for _, request := range batch {
+		stops = append(stops, context.AfterFunc(request.ctx, func() {
+			if remaining.Add(-1) == 0 {
+				cancel()
+			}
+		}))
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestAnalyzeWithOpenAIStopsWhenCancelled(t *testing.T) {
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the body lets the server notice the client hanging up
+		io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	config := openai.DefaultConfig("test")
+	config.BaseURL = srv.URL + "/v1"
+	client := openai.NewClientWithConfig(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := AnalyzeWithOpenAI(ctx, client, "owner/repo", "readme", "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("AnalyzeWithOpenAI() succeeded after its context was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AnalyzeWithOpenAI() kept waiting after its context was cancelled")
+	}
+}
+
+func TestBatchContext(t *testing.T) {
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+
+	ctx, cancel := batchContext([]*batchRequest{{ctx: first}, {ctx: second}})
+	defer cancel()
+
+	cancelFirst()
+	select {
+	case <-ctx.Done():
+		t.Fatal("batch cancelled while one caller is still waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancelSecond()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not cancelled after every caller gave up")
+	}
+}
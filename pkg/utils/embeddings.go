@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingsEnabled is set at startup when the pgvector extension and the embedding column are
+// available. Without it repositories are stored without embeddings and semantic search falls
+// back to substring search.
+var EmbeddingsEnabled bool
+
+// EmbeddingDimensions is the size of the vectors returned by the embedding model.
+const EmbeddingDimensions = 1536
+
+// Embed returns the embedding of text.
+func Embed(ctx context.Context, openaiClient *openai.Client, text string) ([]float32, error) {
+	embeddings, err := embedAll(ctx, openaiClient, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// embedAll returns the embeddings of texts, in order, from a single request.
+func embedAll(ctx context.Context, openaiClient *openai.Client, texts []string) ([][]float32, error) {
+	resp, err := withOpenAIRetry(ctx, "embeddings", func() (openai.EmbeddingResponse, error) {
+		return openaiClient.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input: texts,
+			Model: openai.SmallEmbedding3,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI embeddings error: %v", err)
+	}
+	recordUsage(ctx, resp.Usage)
+
+	embeddings := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index >= 0 && data.Index < len(texts) {
+			embeddings[data.Index] = data.Embedding
+		}
+	}
+	for _, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("no embedding returned from OpenAI")
+		}
+	}
+	return embeddings, nil
+}
+
+// VectorLiteral formats an embedding the way pgvector parses it, e.g. "[0.1,0.2]".
+func VectorLiteral(embedding []float32) string {
+	values := make([]string, 0, len(embedding))
+	for _, value := range embedding {
+		values = append(values, strconv.FormatFloat(float64(value), 'f', -1, 32))
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+// UpdateEmbedding stores the embedding of a repository's name and description.
+func UpdateEmbedding(ctx context.Context, db *sql.DB, openaiClient *openai.Client, fullName, name, description string) error {
+	embedding, err := Embed(ctx, openaiClient, embeddingText(fullName, name, description))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`UPDATE repositories SET embedding = $1::vector WHERE full_name = $2`, VectorLiteral(embedding), NormalizeFullName(fullName))
+	return err
+}
+
+// embeddingText is what a repository is embedded by: its name and description.
+func embeddingText(fullName, name, description string) string {
+	if text := strings.TrimSpace(name + "\n" + description); text != "" {
+		return text
+	}
+	return fullName
+}
+
+// BackfillEmbeddings stores the embeddings of the repositories that don't have one yet, such as
+// those analyzed before embeddings were enabled, embedding batchSize of them per request. It
+// returns how many it stored.
+func BackfillEmbeddings(ctx context.Context, db *sql.DB, openaiClient *openai.Client, batchSize int) (int, error) {
+	stored := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+			SELECT full_name, COALESCE(display_name, ''), COALESCE(description, '')
+			FROM repositories
+			WHERE embedding IS NULL
+			ORDER BY id
+			LIMIT $1
+		`, batchSize)
+		if err != nil {
+			return stored, fmt.Errorf("error querying repositories without embeddings: %v", err)
+		}
+		var fullNames, texts []string
+		for rows.Next() {
+			var fullName, name, description string
+			if err := rows.Scan(&fullName, &name, &description); err != nil {
+				rows.Close()
+				return stored, fmt.Errorf("error scanning repository: %v", err)
+			}
+			fullNames = append(fullNames, fullName)
+			texts = append(texts, embeddingText(fullName, name, description))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return stored, fmt.Errorf("error iterating repositories: %v", err)
+		}
+		if len(fullNames) == 0 {
+			return stored, nil
+		}
+
+		embeddings, err := embedAll(ctx, openaiClient, texts)
+		if err != nil {
+			return stored, err
+		}
+		for i, embedding := range embeddings {
+			if _, err := db.ExecContext(ctx, `UPDATE repositories SET embedding = $1::vector WHERE full_name = $2`, VectorLiteral(embedding), fullNames[i]); err != nil {
+				return stored, fmt.Errorf("error saving embedding of %s: %v", fullNames[i], err)
+			}
+			stored++
+		}
+	}
+}
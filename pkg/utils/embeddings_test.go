@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestEmbedAllOrdersByIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.EmbeddingResponse{
+			Data: []openai.Embedding{{Index: 1, Embedding: []float32{2}}, {Index: 0, Embedding: []float32{1}}},
+		})
+	}))
+	defer srv.Close()
+	config := openai.DefaultConfig("test")
+	config.BaseURL = srv.URL + "/v1"
+	client := openai.NewClientWithConfig(config)
+
+	embeddings, err := embedAll(context.Background(), client, []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("embedAll() error = %v", err)
+	}
+	if embeddings[0][0] != 1 || embeddings[1][0] != 2 {
+		t.Errorf("embeddings = %v, want them in the order of the texts", embeddings)
+	}
+
+	// A text the response has no embedding for is an error rather than a nil vector
+	if _, err := embedAll(context.Background(), client, []string{"first", "second", "third"}); err == nil {
+		t.Error("embedAll() with a missing embedding succeeded")
+	}
+}
+
+func TestEmbeddingText(t *testing.T) {
+	if got := embeddingText("owner/repo", "Server", "Does things"); got != "Server\nDoes things" {
+		t.Errorf("embeddingText() = %q", got)
+	}
+	if got := embeddingText("owner/repo", " ", ""); got != "owner/repo" {
+		t.Errorf("embeddingText() without name and description = %q, want the full name", got)
+	}
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ToSmithery maps a repository and its manifest to the Smithery interchange format. The preferred
+// config becomes the first connection; env vars and headers become the connection's config schema.
+func ToSmithery(repo types.RepoInfo, configs []types.MCPServerConfig) types.SmitheryServer {
+	server := types.SmitheryServer{
+		QualifiedName: NormalizeFullName(repo.FullName),
+		DisplayName:   repo.DisplayName,
+		Description:   repo.Description,
+		Homepage:      repo.URL,
+		IconURL:       repo.Icon,
+		Connections:   []types.SmitheryConnection{},
+	}
+	if server.DisplayName == "" {
+		server.DisplayName = server.QualifiedName
+	}
+
+	for _, config := range configs {
+		connection := types.SmitheryConnection{
+			Type:          "stdio",
+			Command:       config.Command,
+			Args:          config.Args,
+			DeploymentURL: config.URL,
+			ConfigSchema:  smitheryConfigSchema(slices.Concat(config.Env, config.HTTPHeaders)),
+		}
+		if config.URL != "" {
+			connection.Type = "http"
+		}
+
+		if config.Preferred {
+			server.Connections = append([]types.SmitheryConnection{connection}, server.Connections...)
+		} else {
+			server.Connections = append(server.Connections, connection)
+		}
+	}
+	return server
+}
+
+func smitheryConfigSchema(pairs []types.MCPPair) types.SmitheryConfigSchema {
+	schema := types.SmitheryConfigSchema{
+		Type:       "object",
+		Required:   []string{},
+		Properties: map[string]types.SmitheryProperty{},
+	}
+	for _, pair := range pairs {
+		if pair.Key == "" {
+			continue
+		}
+		schema.Properties[pair.Key] = types.SmitheryProperty{
+			Type:        "string",
+			Title:       pair.Name,
+			Description: pair.Description,
+		}
+		if pair.Required {
+			schema.Required = append(schema.Required, pair.Key)
+		}
+	}
+	return schema
+}
+
+// ToMCPConfig renders one config as the mcpServers object clients run, keyed by the server's
+// name. Env var and header values are cleared so the user fills them in; the required and
+// sensitive flags are kept as hints.
+func ToMCPConfig(repo types.RepoInfo, config types.MCPServerConfig) types.Config {
+	name := NormalizeFullName(repo.FullName)
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	config.Preferred = false
+	config.SourceSnippet = ""
+	config.Env = placeholders(config.Env)
+	config.HTTPHeaders = placeholders(config.HTTPHeaders)
+	if config.Env == nil {
+		config.Env = []types.MCPPair{}
+	}
+	return types.Config{MCPServers: map[string]types.MCPServerConfig{name: config}}
+}
+
+func placeholders(pairs []types.MCPPair) []types.MCPPair {
+	if pairs == nil {
+		return nil
+	}
+	result := make([]types.MCPPair, len(pairs))
+	for i, pair := range pairs {
+		pair.Value = ""
+		result[i] = pair
+	}
+	return result
+}
+
+// openAIFunctionName is the pattern OpenAI requires of function names.
+var openAIFunctionName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// jsonSchemaTypes maps the parameter types found in tool definitions to JSON schema types. The
+// analyzer sometimes reports the source language's type names, so common Python and TypeScript
+// ones are accepted too.
+var jsonSchemaTypes = map[string]string{
+	"string": "string", "str": "string",
+	"number": "number", "float": "number",
+	"integer": "integer", "int": "integer",
+	"boolean": "boolean", "bool": "boolean",
+	"array": "array", "list": "array",
+	"object": "object", "dict": "object",
+}
+
+// ToOpenAIFunctions converts tool definitions into OpenAI function calling tools. Tools that can't
+// be converted are returned as skipped with the reason: names OpenAI rejects, and parameters of a
+// type that has no JSON schema equivalent.
+//
+// Stored tool definitions only have flat parameters with a type, description and required flag.
+// Item types of arrays, properties of objects, enums, formats and defaults are unknown, so arrays
+// accept any items and objects any properties, and the functions are not marked strict.
+func ToOpenAIFunctions(tools []types.MCPTool) ([]openai.Tool, []types.SkippedTool) {
+	converted := []openai.Tool{}
+	skipped := []types.SkippedTool{}
+	for _, tool := range tools {
+		if !openAIFunctionName.MatchString(tool.Name) {
+			skipped = append(skipped, types.SkippedTool{Name: tool.Name, Reason: "name must be 1 to 64 letters, digits, underscores or dashes"})
+			continue
+		}
+
+		properties := map[string]any{}
+		required := []string{}
+		var reason string
+		for _, name := range slices.Sorted(maps.Keys(tool.InputSchema.Properties)) {
+			property := tool.InputSchema.Properties[name]
+			schema := map[string]any{}
+			if property.Type != "" {
+				schemaType, ok := jsonSchemaTypes[strings.ToLower(strings.TrimSpace(property.Type))]
+				if !ok {
+					reason = fmt.Sprintf("parameter %q has unsupported type %q", name, property.Type)
+					break
+				}
+				schema["type"] = schemaType
+				if schemaType == "array" {
+					schema["items"] = map[string]any{}
+				}
+			}
+			if property.Description != "" {
+				schema["description"] = property.Description
+			}
+			properties[name] = schema
+			if property.Required {
+				required = append(required, name)
+			}
+		}
+		if reason != "" {
+			skipped = append(skipped, types.SkippedTool{Name: tool.Name, Reason: reason})
+			continue
+		}
+
+		converted = append(converted, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": properties,
+					"required":   required,
+				},
+			},
+		})
+	}
+	return converted, skipped
+}
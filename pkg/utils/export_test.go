@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+func TestToSmithery(t *testing.T) {
+	repo := types.RepoInfo{
+		FullName:    "ModelContextProtocol/Servers/src/github",
+		DisplayName: "GitHub",
+		Description: "GitHub API",
+		URL:         "https://github.com/modelcontextprotocol/servers",
+		Icon:        "https://example.com/icon.png",
+	}
+	configs := []types.MCPServerConfig{
+		{
+			Command: "docker",
+			Args:    []string{"run", "-i", "ghcr.io/github/github-mcp-server"},
+			Env:     []types.MCPPair{{Key: "GITHUB_TOKEN", Name: "token", Description: "Personal access token", Required: true}},
+		},
+		{
+			Command:   "npx",
+			Args:      []string{"-y", "@modelcontextprotocol/server-github"},
+			Env:       []types.MCPPair{{Key: "GITHUB_TOKEN", Name: "token", Required: true}, {Key: "GITHUB_HOST"}, {Name: "no key"}},
+			Preferred: true,
+		},
+		{
+			URL:         "https://api.githubcopilot.com/mcp/",
+			HTTPHeaders: []types.MCPPair{{Key: "Authorization", Description: "Bearer token", Required: true}},
+		},
+	}
+
+	want := types.SmitheryServer{
+		QualifiedName: "modelcontextprotocol/servers/src/github",
+		DisplayName:   "GitHub",
+		Description:   "GitHub API",
+		Homepage:      "https://github.com/modelcontextprotocol/servers",
+		IconURL:       "https://example.com/icon.png",
+		Connections: []types.SmitheryConnection{
+			{
+				Type:    "stdio",
+				Command: "npx",
+				Args:    []string{"-y", "@modelcontextprotocol/server-github"},
+				ConfigSchema: types.SmitheryConfigSchema{
+					Type:     "object",
+					Required: []string{"GITHUB_TOKEN"},
+					Properties: map[string]types.SmitheryProperty{
+						"GITHUB_TOKEN": {Type: "string", Title: "token"},
+						"GITHUB_HOST":  {Type: "string"},
+					},
+				},
+			},
+			{
+				Type:    "stdio",
+				Command: "docker",
+				Args:    []string{"run", "-i", "ghcr.io/github/github-mcp-server"},
+				ConfigSchema: types.SmitheryConfigSchema{
+					Type:     "object",
+					Required: []string{"GITHUB_TOKEN"},
+					Properties: map[string]types.SmitheryProperty{
+						"GITHUB_TOKEN": {Type: "string", Title: "token", Description: "Personal access token"},
+					},
+				},
+			},
+			{
+				Type:          "http",
+				DeploymentURL: "https://api.githubcopilot.com/mcp/",
+				ConfigSchema: types.SmitheryConfigSchema{
+					Type:     "object",
+					Required: []string{"Authorization"},
+					Properties: map[string]types.SmitheryProperty{
+						"Authorization": {Type: "string", Description: "Bearer token"},
+					},
+				},
+			},
+		},
+	}
+	if got := ToSmithery(repo, configs); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToSmithery() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestToSmitheryEmpty(t *testing.T) {
+	server := ToSmithery(types.RepoInfo{FullName: "Owner/Repo"}, nil)
+	if server.DisplayName != "owner/repo" {
+		t.Errorf("DisplayName = %q, want the qualified name", server.DisplayName)
+	}
+
+	body, err := json.Marshal(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Consumers expect a list even when there is nothing to connect with
+	if !strings.Contains(string(body), `"connections":[]`) {
+		t.Errorf("JSON %s doesn't have an empty connections list", body)
+	}
+	for _, field := range []string{"homepage", "iconUrl"} {
+		if strings.Contains(string(body), field) {
+			t.Errorf("JSON %s has empty %s", body, field)
+		}
+	}
+}
+
+func TestToSmitheryConfigSchemaJSON(t *testing.T) {
+	server := ToSmithery(types.RepoInfo{FullName: "owner/repo"}, []types.MCPServerConfig{{Command: "uvx", Args: []string{"server"}}})
+
+	body, err := json.Marshal(server.Connections[0].ConfigSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A config without env vars still has a valid object schema
+	if want := `{"type":"object","required":[],"properties":{}}`; string(body) != want {
+		t.Errorf("config schema = %s, want %s", body, want)
+	}
+}
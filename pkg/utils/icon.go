@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// iconSearchLines limits how far into a README an image is still considered its logo.
+const iconSearchLines = 20
+
+var (
+	htmlImagePattern     = regexp.MustCompile(`(?i)<img[^>]+src=["']([^"']+)["']`)
+	markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)`)
+
+	// badgeHints mark images that are status badges rather than logos.
+	badgeHints = []string{"shields.io", "badge", "badgen.net", "codecov.io", "travis-ci", "/actions/workflows/", "smithery.ai"}
+
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico"}
+	imageHosts      = []string{"avatars.githubusercontent.com", "user-images.githubusercontent.com", "private-user-images.githubusercontent.com"}
+)
+
+// ReadmeIcon returns the icon a README specifies for its server: an "icon" key in YAML
+// frontmatter, or else the first image near the top that isn't a badge. Relative paths are
+// resolved against rawBase, the raw.githubusercontent.com URL of the README's directory.
+// It returns "" when there is no valid image URL.
+func ReadmeIcon(readmeContent, rawBase string) string {
+	lines := strings.Split(strings.ReplaceAll(readmeContent, "\r\n", "\n"), "\n")
+
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i, line := range lines[1:] {
+			if strings.TrimSpace(line) == "---" {
+				lines = lines[i+2:]
+				break
+			}
+			if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "icon" {
+				if icon := resolveImageURL(strings.Trim(strings.TrimSpace(value), `"'`), rawBase); icon != "" {
+					return icon
+				}
+			}
+		}
+	}
+
+	top := strings.Join(lines[:min(len(lines), iconSearchLines)], "\n")
+	type candidate struct {
+		pos int
+		src string
+	}
+	var candidates []candidate
+	for _, pattern := range []*regexp.Regexp{htmlImagePattern, markdownImagePattern} {
+		for _, match := range pattern.FindAllStringSubmatchIndex(top, -1) {
+			candidates = append(candidates, candidate{pos: match[0], src: top[match[2]:match[3]]})
+		}
+	}
+	// Both patterns were searched separately, so restore document order
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return a.pos - b.pos
+	})
+
+	for _, c := range candidates {
+		if isBadge(c.src) {
+			continue
+		}
+		if icon := resolveImageURL(c.src, rawBase); icon != "" {
+			return icon
+		}
+	}
+	return ""
+}
+
+func isBadge(src string) bool {
+	src = strings.ToLower(src)
+	for _, hint := range badgeHints {
+		if strings.Contains(src, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImageURL makes src absolute, points GitHub blob links at the raw file and returns ""
+// unless the result is a valid image URL.
+func resolveImageURL(src, rawBase string) string {
+	ref, err := url.Parse(strings.TrimSpace(src))
+	if err != nil || src == "" {
+		return ""
+	}
+	if !ref.IsAbs() {
+		base, err := url.Parse(rawBase)
+		if err != nil {
+			return ""
+		}
+		ref = base.ResolveReference(ref)
+	}
+
+	// https://github.com/owner/repo/blob/branch/logo.png is an HTML page; serve the file itself
+	if ref.Host == "github.com" {
+		if parts := strings.SplitN(strings.TrimPrefix(ref.Path, "/"), "/", 4); len(parts) == 4 && (parts[2] == "blob" || parts[2] == "raw") {
+			ref.Host = "raw.githubusercontent.com"
+			ref.Path = "/" + parts[0] + "/" + parts[1] + "/" + parts[3]
+			ref.RawQuery = ""
+		}
+	}
+
+	if !ValidImageURL(ref.String()) {
+		return ""
+	}
+	return ref.String()
+}
+
+// ValidImageURL reports whether raw is an https URL that points to an image, judged by its
+// file extension or by it being served from a known image host.
+func ValidImageURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return false
+	}
+	if slices.Contains(imageHosts, u.Hostname()) {
+		return true
+	}
+	return slices.Contains(imageExtensions, strings.ToLower(path.Ext(u.Path)))
+}
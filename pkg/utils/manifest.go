@@ -0,0 +1,389 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// ParseManifest decodes a stored manifest. Empty values such as "" or "{}" decode to no configs.
+func ParseManifest(manifest string) ([]types.MCPServerConfig, error) {
+	manifest = strings.TrimSpace(manifest)
+	if manifest == "" || manifest == "{}" || manifest == "null" {
+		return []types.MCPServerConfig{}, nil
+	}
+
+	var configs []types.MCPServerConfig
+	if err := json.Unmarshal([]byte(manifest), &configs); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return configs, nil
+}
+
+// ValidateManifest checks that every config can actually be run.
+func ValidateManifest(configs []types.MCPServerConfig) error {
+	for i, config := range configs {
+		if config.Command == "" && config.URL == "" {
+			return fmt.Errorf("config %d must have either a command or a url", i)
+		}
+		if config.Command != "" && config.URL != "" {
+			return fmt.Errorf("config %d must not have both a command and a url", i)
+		}
+		if IsInstallCommand(config) {
+			return fmt.Errorf("config %d is an installation step, not a command that runs the server", i)
+		}
+		if config.Command == "npx" {
+			if err := validateNpxArgs(config.Args); err != nil {
+				return fmt.Errorf("config %d: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// NormalizeManifest returns a copy of configs with whitespace trimmed and the preferred config recomputed.
+func NormalizeManifest(configs []types.MCPServerConfig) []types.MCPServerConfig {
+	normalized := make([]types.MCPServerConfig, 0, len(configs))
+	for _, config := range configs {
+		config.Command = strings.TrimSpace(config.Command)
+		config.URL = strings.TrimSpace(config.URL)
+		config.Args = NormalizeNpxArgs(config.Command, trimArgs(config.Args))
+		config.Env = normalizePairs(config.Env)
+		config.HTTPHeaders = normalizePairs(config.HTTPHeaders)
+		config.Preferred = false
+		normalized = append(normalized, config)
+	}
+
+	MarkPreferred(normalized)
+	return normalized
+}
+
+func trimArgs(args []string) []string {
+	if args == nil {
+		return nil
+	}
+	trimmed := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			trimmed = append(trimmed, arg)
+		}
+	}
+	return trimmed
+}
+
+func normalizePairs(pairs []types.MCPPair) []types.MCPPair {
+	if pairs == nil {
+		return nil
+	}
+	normalized := make([]types.MCPPair, 0, len(pairs))
+	for _, pair := range pairs {
+		pair.Key = strings.TrimSpace(pair.Key)
+		pair.Name = strings.TrimSpace(pair.Name)
+		pair.Description = strings.TrimSpace(pair.Description)
+		normalized = append(normalized, pair)
+	}
+	return normalized
+}
+
+// npmSpecPattern matches an npm package specifier: an optionally scoped package name with an
+// optional version, tag or range, e.g. "@modelcontextprotocol/server-github@latest".
+var npmSpecPattern = regexp.MustCompile(`^(@[a-z0-9][a-z0-9._~-]*/)?[a-z0-9][a-z0-9._~-]*(@[^\s@/]+)?$`)
+
+// npmVersionPattern matches a version or tag that was split from its package name, e.g. "@latest".
+var npmVersionPattern = regexp.MustCompile(`^@(latest|next|canary|beta|alpha|[v^~]?\d[\w.+-]*)$`)
+
+// npxPackageFlags are npx options whose value is the package to install.
+var npxPackageFlags = []string{"-p", "--package"}
+
+// NormalizeNpxArgs fixes the args of an npx command: it adds -y so npx doesn't prompt for
+// confirmation (which hangs a stdio server), and rejoins a package specifier that was split
+// across args, such as ["@scope", "/server"] or ["@scope/server", "@latest"]. Args of other
+// commands are returned unchanged.
+func NormalizeNpxArgs(command string, args []string) []string {
+	if command != "npx" || len(args) == 0 {
+		return args
+	}
+
+	normalized := make([]string, 0, len(args)+1)
+	if !slices.Contains(args, "-y") && !slices.Contains(args, "--yes") {
+		normalized = append(normalized, "-y")
+	}
+
+	pkg := npxPackageIndex(args)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if i == pkg {
+			for i+1 < len(args) {
+				joined, ok := joinSpec(arg, args[i+1])
+				if !ok {
+					break
+				}
+				arg = joined
+				i++
+			}
+		}
+		normalized = append(normalized, arg)
+	}
+	return normalized
+}
+
+// joinSpec returns the package specifier formed by current and next when next is a fragment
+// of it that ended up in its own arg.
+func joinSpec(current, next string) (string, bool) {
+	if strings.HasPrefix(next, "-") {
+		return "", false
+	}
+	hasVersion := strings.Contains(strings.TrimPrefix(current, "@"), "@")
+	switch {
+	case strings.HasPrefix(current, "@") && !strings.Contains(current, "/"):
+		// A scope on its own is never a package: "@scope" "server"
+		return current + "/" + strings.TrimPrefix(next, "/"), true
+	case strings.HasSuffix(current, "/"):
+		// "@scope/" "server"; a complete name followed by "/path" is a path argument
+		return current + strings.TrimPrefix(next, "/"), true
+	case !hasVersion && npmVersionPattern.MatchString(next):
+		return current + next, true
+	}
+	return "", false
+}
+
+// npxPackageIndex returns the index of the package specifier in npx args, or -1.
+func npxPackageIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if slices.Contains(npxPackageFlags, arg) {
+			return i + 1
+		}
+		if strings.HasPrefix(arg, "--package=") || strings.HasPrefix(arg, "-") {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func validateNpxArgs(args []string) error {
+	i := npxPackageIndex(args)
+	if i == -1 || i >= len(args) {
+		return fmt.Errorf("npx config has no package")
+	}
+	if !npmSpecPattern.MatchString(strings.ToLower(args[i])) {
+		return fmt.Errorf("%q is not a valid npm package specifier", args[i])
+	}
+	return nil
+}
+
+// installVerbs maps package manager commands to the subcommands that install a package rather than run it.
+var installVerbs = map[string][]string{
+	"npm":   {"install", "i", "add", "ci"},
+	"pnpm":  {"install", "i", "add"},
+	"yarn":  {"install", "add"},
+	"bun":   {"install", "i", "add"},
+	"pip":   {"install"},
+	"pip3":  {"install"},
+	"pipx":  {"install"},
+	"brew":  {"install"},
+	"go":    {"install", "get"},
+	"cargo": {"install"},
+	"uv":    {"add", "sync"},
+}
+
+// IsInstallCommand reports whether a config describes an installation step (e.g. `npm install -g foo`)
+// instead of the command that runs the server.
+func IsInstallCommand(config types.MCPServerConfig) bool {
+	tokens := append(strings.Fields(config.Command), config.Args...)
+	if len(tokens) > 0 && tokens[0] == "uv" && len(tokens) > 1 && tokens[1] == "pip" {
+		// uv pip install ...
+		tokens = tokens[1:]
+		tokens[0] = "pip"
+	}
+	if len(tokens) < 2 {
+		return false
+	}
+	verbs, ok := installVerbs[tokens[0]]
+	if !ok {
+		return false
+	}
+	for _, token := range tokens[1:] {
+		if strings.HasPrefix(token, "-") {
+			continue
+		}
+		return slices.Contains(verbs, token)
+	}
+	return false
+}
+
+// DropInstallCommands removes configs that only describe how to install the server.
+func DropInstallCommands(configs []types.MCPServerConfig) []types.MCPServerConfig {
+	result := make([]types.MCPServerConfig, 0, len(configs))
+	for _, config := range configs {
+		if IsInstallCommand(config) {
+			continue
+		}
+		result = append(result, config)
+	}
+	return result
+}
+
+// PreferredConfig returns the config marked as preferred, falling back to the first config.
+func PreferredConfig(configs []types.MCPServerConfig) (types.MCPServerConfig, bool) {
+	for _, config := range configs {
+		if config.Preferred {
+			return config, true
+		}
+	}
+	if len(configs) > 0 {
+		return configs[0], true
+	}
+	return types.MCPServerConfig{}, false
+}
+
+// Requirements splits the env vars and headers of the preferred config into required and optional.
+func Requirements(configs []types.MCPServerConfig) *types.ConfigRequirements {
+	config, ok := PreferredConfig(configs)
+	if !ok {
+		return nil
+	}
+
+	requirements := &types.ConfigRequirements{
+		RequiredEnv: []types.MCPPair{},
+		OptionalEnv: []types.MCPPair{},
+	}
+	for _, env := range config.Env {
+		if env.Required {
+			requirements.RequiredEnv = append(requirements.RequiredEnv, env)
+		} else {
+			requirements.OptionalEnv = append(requirements.OptionalEnv, env)
+		}
+	}
+	for _, header := range config.HTTPHeaders {
+		if header.Required {
+			requirements.RequiredHeaders = append(requirements.RequiredHeaders, header)
+		} else {
+			requirements.OptionalHeaders = append(requirements.OptionalHeaders, header)
+		}
+	}
+	return requirements
+}
+
+// maxSnippetLength caps the README excerpt stored with a config.
+const maxSnippetLength = 2000
+
+// capSnippet trims a source snippet and cuts it to maxSnippetLength bytes on a rune boundary.
+func capSnippet(snippet string) string {
+	snippet = strings.TrimSpace(snippet)
+	if len(snippet) <= maxSnippetLength {
+		return snippet
+	}
+	cut := maxSnippetLength
+	for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+		cut--
+	}
+	return snippet[:cut] + "…"
+}
+
+// DedupeConfigs collapses configs that only differ by whitespace or env metadata, i.e. that
+// have the same command, args and url. Env vars and headers of collapsed configs are merged.
+func DedupeConfigs(configs []types.MCPServerConfig) []types.MCPServerConfig {
+	result := make([]types.MCPServerConfig, 0, len(configs))
+	index := make(map[string]int)
+	for _, config := range configs {
+		config.Command = strings.TrimSpace(config.Command)
+		config.URL = strings.TrimSpace(config.URL)
+		config.Args = NormalizeNpxArgs(config.Command, trimArgs(config.Args))
+		config.SourceSnippet = capSnippet(config.SourceSnippet)
+
+		key := strings.Join(append([]string{config.Command, config.URL}, config.Args...), "\x00")
+		if i, ok := index[key]; ok {
+			result[i].Env = mergePairs(result[i].Env, config.Env)
+			result[i].HTTPHeaders = mergePairs(result[i].HTTPHeaders, config.HTTPHeaders)
+			if result[i].SourceSnippet == "" {
+				result[i].SourceSnippet = config.SourceSnippet
+			}
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, config)
+	}
+	return result
+}
+
+// mergePairs adds the pairs from extra whose key isn't already in pairs, and fills in
+// missing names and descriptions of existing pairs.
+func mergePairs(pairs, extra []types.MCPPair) []types.MCPPair {
+	for _, pair := range extra {
+		i := slices.IndexFunc(pairs, func(p types.MCPPair) bool {
+			return strings.TrimSpace(p.Key) == strings.TrimSpace(pair.Key)
+		})
+		if i == -1 {
+			pairs = append(pairs, pair)
+			continue
+		}
+		if pairs[i].Name == "" {
+			pairs[i].Name = pair.Name
+		}
+		if pairs[i].Description == "" {
+			pairs[i].Description = pair.Description
+		}
+		pairs[i].Required = pairs[i].Required || pair.Required
+		pairs[i].Sensitive = pairs[i].Sensitive || pair.Sensitive
+	}
+	return pairs
+}
+
+// RefreshEnvDocs updates the env var and header documentation of configs from a fresh analysis,
+// matching configs by command, url and args, or else by command. Existing values and everything
+// but the documentation of a config are kept; newly documented keys are added.
+func RefreshEnvDocs(configs, analyzed []types.MCPServerConfig) []types.MCPServerConfig {
+	result := make([]types.MCPServerConfig, len(configs))
+	for i, config := range configs {
+		match := slices.IndexFunc(analyzed, func(a types.MCPServerConfig) bool {
+			return a.Command == config.Command && a.URL == config.URL && slices.Equal(a.Args, config.Args)
+		})
+		if match == -1 {
+			match = slices.IndexFunc(analyzed, func(a types.MCPServerConfig) bool {
+				return a.Command == config.Command && a.URL == config.URL
+			})
+		}
+		if match != -1 {
+			config.Env = refreshPairs(config.Env, analyzed[match].Env)
+			config.HTTPHeaders = refreshPairs(config.HTTPHeaders, analyzed[match].HTTPHeaders)
+		}
+		result[i] = config
+	}
+	return result
+}
+
+// refreshPairs replaces the documentation of pairs with the one in fresh, keeping their values.
+func refreshPairs(pairs, fresh []types.MCPPair) []types.MCPPair {
+	pairs = slices.Clone(pairs)
+	for _, pair := range fresh {
+		key := strings.TrimSpace(pair.Key)
+		if key == "" {
+			continue
+		}
+		i := slices.IndexFunc(pairs, func(p types.MCPPair) bool {
+			return strings.TrimSpace(p.Key) == key
+		})
+		if i == -1 {
+			pair.Key = key
+			pairs = append(pairs, pair)
+			continue
+		}
+		if pair.Name != "" {
+			pairs[i].Name = pair.Name
+		}
+		if pair.Description != "" {
+			pairs[i].Description = pair.Description
+		}
+		pairs[i].Required = pair.Required
+		pairs[i].Sensitive = pair.Sensitive
+		pairs[i].File = pair.File
+	}
+	return pairs
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+func TestNormalizeNpxArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "adds -y",
+			args: []string{"@modelcontextprotocol/server-github"},
+			want: []string{"-y", "@modelcontextprotocol/server-github"},
+		},
+		{
+			name: "keeps --yes",
+			args: []string{"--yes", "server-foo"},
+			want: []string{"--yes", "server-foo"},
+		},
+		{
+			name: "scope split from name",
+			args: []string{"-y", "@modelcontextprotocol", "server-github"},
+			want: []string{"-y", "@modelcontextprotocol/server-github"},
+		},
+		{
+			name: "scope split before slash",
+			args: []string{"-y", "@modelcontextprotocol", "/server-github"},
+			want: []string{"-y", "@modelcontextprotocol/server-github"},
+		},
+		{
+			name: "scope with trailing slash",
+			args: []string{"-y", "@modelcontextprotocol/", "server-github"},
+			want: []string{"-y", "@modelcontextprotocol/server-github"},
+		},
+		{
+			name: "version split from name",
+			args: []string{"-y", "@upstash/context7-mcp", "@latest"},
+			want: []string{"-y", "@upstash/context7-mcp@latest"},
+		},
+		{
+			name: "absolute path after scoped package",
+			args: []string{"-y", "@modelcontextprotocol/server-filesystem", "/Users/me/Desktop"},
+			want: []string{"-y", "@modelcontextprotocol/server-filesystem", "/Users/me/Desktop"},
+		},
+		{
+			name: "absolute path after unscoped package",
+			args: []string{"mcp-server-foo", "/tmp"},
+			want: []string{"-y", "mcp-server-foo", "/tmp"},
+		},
+		{
+			name: "versioned package keeps following args",
+			args: []string{"-y", "server-foo@1.2.0", "@latest"},
+			want: []string{"-y", "server-foo@1.2.0", "@latest"},
+		},
+		{
+			name: "package flag",
+			args: []string{"-y", "--package", "@scope", "server", "server-bin"},
+			want: []string{"-y", "--package", "@scope/server", "server-bin"},
+		},
+		{
+			name: "flag after split scope is not joined",
+			args: []string{"-y", "@scope", "--stdio"},
+			want: []string{"-y", "@scope", "--stdio"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeNpxArgs("npx", tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("NormalizeNpxArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNpxArgsOtherCommands(t *testing.T) {
+	args := []string{"mcp-server-foo", "/tmp"}
+	if got := NormalizeNpxArgs("uvx", args); !slices.Equal(got, args) {
+		t.Errorf("NormalizeNpxArgs(uvx) = %q, want the args unchanged", got)
+	}
+}
+
+func TestValidateManifestNpx(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "scoped package with path", args: []string{"-y", "@modelcontextprotocol/server-filesystem", "/Users/me/Desktop"}},
+		{name: "versioned package", args: []string{"-y", "@upstash/context7-mcp@latest"}},
+		{name: "package flag", args: []string{"-y", "--package=foo", "foo-bin"}},
+		{name: "no package", args: []string{"-y"}, wantErr: true},
+		{name: "no args", wantErr: true},
+		{name: "bare scope", args: []string{"-y", "@modelcontextprotocol"}, wantErr: true},
+		{name: "url instead of package", args: []string{"-y", "https://example.com/server.js"}, wantErr: true},
+		{name: "spaces in package", args: []string{"-y", "server foo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateManifest([]types.MCPServerConfig{{Command: "npx", Args: tt.args}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateManifest(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeManifestKeepsFilesystemServerValid(t *testing.T) {
+	configs := NormalizeManifest([]types.MCPServerConfig{{
+		Command: " npx ",
+		Args:    []string{"@modelcontextprotocol/server-filesystem", " /Users/me/Desktop ", "/Users/me/Downloads"},
+	}})
+	want := []string{"-y", "@modelcontextprotocol/server-filesystem", "/Users/me/Desktop", "/Users/me/Downloads"}
+	if !slices.Equal(configs[0].Args, want) {
+		t.Errorf("args = %q, want %q", configs[0].Args, want)
+	}
+	if err := ValidateManifest(configs); err != nil {
+		t.Errorf("ValidateManifest() error = %v", err)
+	}
+}
+
+func TestDedupeConfigsKeepsPathArgs(t *testing.T) {
+	configs := DedupeConfigs([]types.MCPServerConfig{
+		{Command: "npx", Args: []string{"-y", "@modelcontextprotocol/server-filesystem", "/Users/me/Desktop"}},
+		{Command: "npx", Args: []string{"@modelcontextprotocol/server-filesystem", "/Users/me/Desktop"}},
+	})
+	if len(configs) != 1 {
+		t.Fatalf("got %d configs, want the two collapsed into 1", len(configs))
+	}
+	want := []string{"-y", "@modelcontextprotocol/server-filesystem", "/Users/me/Desktop"}
+	if !slices.Equal(configs[0].Args, want) {
+		t.Errorf("args = %q, want %q", configs[0].Args, want)
+	}
+}
+
+func TestIsInstallCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		config types.MCPServerConfig
+		want   bool
+	}{
+		{name: "npm install -g", config: types.MCPServerConfig{Command: "npm", Args: []string{"install", "-g", "server-foo"}}, want: true},
+		{name: "npm i", config: types.MCPServerConfig{Command: "npm", Args: []string{"i", "server-foo"}}, want: true},
+		{name: "flags before the verb", config: types.MCPServerConfig{Command: "npm", Args: []string{"--global", "install", "server-foo"}}, want: true},
+		{name: "whole step in command", config: types.MCPServerConfig{Command: "pip install server-foo"}, want: true},
+		{name: "uv pip install", config: types.MCPServerConfig{Command: "uv", Args: []string{"pip", "install", "server-foo"}}, want: true},
+		{name: "uv add", config: types.MCPServerConfig{Command: "uv", Args: []string{"add", "server-foo"}}, want: true},
+		{name: "go install", config: types.MCPServerConfig{Command: "go", Args: []string{"install", "github.com/foo/server@latest"}}, want: true},
+		{name: "brew install", config: types.MCPServerConfig{Command: "brew", Args: []string{"install", "server-foo"}}, want: true},
+		{name: "npx", config: types.MCPServerConfig{Command: "npx", Args: []string{"-y", "server-foo"}}},
+		{name: "uv run", config: types.MCPServerConfig{Command: "uv", Args: []string{"run", "server-foo"}}},
+		{name: "uvx", config: types.MCPServerConfig{Command: "uvx", Args: []string{"server-foo"}}},
+		{name: "go run", config: types.MCPServerConfig{Command: "go", Args: []string{"run", "./cmd/server"}}},
+		{name: "npm run", config: types.MCPServerConfig{Command: "npm", Args: []string{"run", "start"}}},
+		{name: "installed binary", config: types.MCPServerConfig{Command: "server-foo", Args: []string{"--stdio"}}},
+		{name: "package manager alone", config: types.MCPServerConfig{Command: "pip"}},
+		{name: "remote", config: types.MCPServerConfig{URL: "https://example.com/mcp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsInstallCommand(tt.config); got != tt.want {
+				t.Errorf("IsInstallCommand(%s %q) = %v, want %v", tt.config.Command, tt.config.Args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropInstallCommands(t *testing.T) {
+	install := types.MCPServerConfig{Command: "npm", Args: []string{"install", "-g", "server-foo"}}
+	run := types.MCPServerConfig{Command: "server-foo", Args: []string{"--stdio"}}
+	npx := types.MCPServerConfig{Command: "npx", Args: []string{"-y", "server-foo"}}
+
+	got := DropInstallCommands([]types.MCPServerConfig{install, run, npx})
+	if len(got) != 2 || got[0].Command != "server-foo" || got[1].Command != "npx" {
+		t.Errorf("DropInstallCommands() = %+v, want the run and npx configs in order", got)
+	}
+	if got := DropInstallCommands([]types.MCPServerConfig{install}); got == nil || len(got) != 0 {
+		t.Errorf("DropInstallCommands() of only an install step = %#v, want an empty slice", got)
+	}
+}
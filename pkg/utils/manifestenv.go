@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// SyncManifestEnv replaces the manifest_env rows of a repository with the env vars of its
+// manifest. It must run in the same transaction that writes the manifest so the two never
+// disagree. Env vars that appear in several configs are stored once.
+func SyncManifestEnv(tx *sql.Tx, repoID int, manifest string) error {
+	configs, err := ParseManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM manifest_env WHERE repo_id = $1`, repoID); err != nil {
+		return fmt.Errorf("error clearing env vars of repository %d: %v", repoID, err)
+	}
+
+	var envs []types.MCPPair
+	for _, config := range configs {
+		envs = mergePairs(envs, config.Env)
+	}
+	for _, env := range envs {
+		key := strings.TrimSpace(env.Key)
+		if key == "" {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO manifest_env (repo_id, key, name, required, sensitive)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (repo_id, key) DO NOTHING
+		`, repoID, key, strings.TrimSpace(env.Name), env.Required, env.Sensitive)
+		if err != nil {
+			return fmt.Errorf("error storing env var %s of repository %d: %v", key, repoID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// ReposScraped counts the repositories handled by the scraper, by result: added, skipped or error.
+	ReposScraped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "catalog_repos_scraped_total",
+		Help: "Repositories processed by the scraper, by result.",
+	}, []string{"result"})
+
+	// ReposSkipped counts the repositories the scraper did not analyze, by reason.
+	ReposSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "catalog_repos_skipped_total",
+		Help: "Repositories the scraper skipped, by reason.",
+	}, []string{"reason"})
+
+	openAIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "catalog_openai_requests_total",
+		Help: "OpenAI API requests, by operation and status.",
+	}, []string{"operation", "status"})
+
+	openAIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "catalog_openai_request_duration_seconds",
+		Help:    "Latency of OpenAI API requests, by operation.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"operation"})
+
+	githubRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "catalog_github_requests_total",
+		Help: "GitHub API requests made through a rate limiter, by status.",
+	}, []string{"status"})
+
+	rateLimitWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "catalog_github_rate_limit_waits_total",
+		Help: "Times GitHub reported a rate limit and callers had to wait, by limit type.",
+	}, []string{"type"})
+
+	rateLimitWaitSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "catalog_github_rate_limit_wait_seconds_total",
+		Help: "Time spent waiting for GitHub rate limits to reset, by limit type.",
+	}, []string{"type"})
+
+	// HTTPDuration measures the API handlers, by method, route pattern and status code.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "catalog_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests, by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// observeOpenAI records an OpenAI request that was started at start.
+func observeOpenAI(operation string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	openAIRequests.WithLabelValues(operation, status).Inc()
+	openAIDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// observeRateLimitWait records that callers have to wait d for the given kind of rate limit.
+func observeRateLimitWait(limitType string, d time.Duration) {
+	rateLimitWaits.WithLabelValues(limitType).Inc()
+	rateLimitWaitSeconds.WithLabelValues(limitType).Add(max(d, 0).Seconds())
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// maxLoggedContent bounds how much of a malformed response is logged.
+const maxLoggedContent = 4 << 10
+
+// unmarshalOpenAIJSON parses the JSON of an OpenAI response made for operation into v. The raw
+// content is logged when it can't be parsed, so that malformed responses can be looked into.
+func unmarshalOpenAIJSON(operation, content string, v any) error {
+	if err := json.Unmarshal([]byte(extractJSON(content)), v); err != nil {
+		if len(content) > maxLoggedContent {
+			content = content[:maxLoggedContent] + "..."
+		}
+		slog.Warn("Malformed OpenAI response", "operation", operation, "content", content, "error", err)
+		return err
+	}
+	return nil
+}
+
+// extractJSON returns the JSON value in content. Despite the JSON response format the model
+// occasionally wraps its answer in a ```json fence or adds a sentence before or after it; both
+// are stripped. Content that is valid JSON already is returned as it is.
+func extractJSON(content string) string {
+	content = strings.TrimSpace(content)
+	if json.Valid([]byte(content)) {
+		return content
+	}
+
+	if _, fenced, ok := strings.Cut(content, "```"); ok {
+		// Drop the language tag of the fence, e.g. "json"
+		fenced = strings.TrimLeft(fenced, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		fenced, _, _ = strings.Cut(fenced, "```")
+		content = strings.TrimSpace(fenced)
+	}
+
+	start := strings.IndexAny(content, "{[")
+	end := strings.LastIndexAny(content, "}]")
+	if start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+	return content
+}
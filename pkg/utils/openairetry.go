@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	openAIRetryBase = time.Second
+	openAIRetryMax  = 30 * time.Second
+)
+
+// openAIMaxRetries is how often a failed OpenAI request is retried, OPENAI_MAX_RETRIES (default 3).
+var openAIMaxRetries = sync.OnceValue(func() int {
+	if retries, err := strconv.Atoi(os.Getenv("OPENAI_MAX_RETRIES")); err == nil && retries >= 0 {
+		return retries
+	}
+	return 3
+})
+
+// withOpenAIRetry calls do, recording every attempt under operation, and retries it with
+// exponential backoff and jitter while it fails with a transient error. The last error is
+// returned once the retries are used up.
+func withOpenAIRetry[T any](ctx context.Context, operation string, do func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		result, err := do()
+		observeOpenAI(operation, start, err)
+		if err == nil || attempt >= openAIMaxRetries() || !retryableOpenAIError(ctx, err) {
+			return result, err
+		}
+
+		delay := min(openAIRetryBase<<attempt, openAIRetryMax)
+		delay = delay/2 + rand.N(delay/2+1)
+		slog.Warn("OpenAI request failed, retrying", "operation", operation, "attempt", attempt+1, "delay", delay, "error", err)
+		if SleepContext(ctx, delay) != nil {
+			return result, err
+		}
+	}
+}
+
+// retryableOpenAIError reports whether err is worth retrying: rate limits, server errors and
+// network failures. Malformed requests, authentication errors and an exhausted quota are not.
+func retryableOpenAIError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == "insufficient_quota" {
+			return false
+		}
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retryableStatus(requestErr.HTTPStatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+// knownPlatforms are the platform values a manifest may carry, in display order. "wsl" means the
+// server runs on Windows only through WSL.
+var knownPlatforms = []string{"macos", "linux", "windows", "wsl"}
+
+var platformAliases = map[string]string{
+	"mac":           "macos",
+	"mac os":        "macos",
+	"mac os x":      "macos",
+	"osx":           "macos",
+	"os x":          "macos",
+	"darwin":        "macos",
+	"win":           "windows",
+	"win32":         "windows",
+	"windows (wsl)": "wsl",
+}
+
+// NormalizePlatforms maps the platforms the analyzer extracted to the known values, dropping
+// anything it doesn't recognize and duplicates.
+func NormalizePlatforms(platforms []string) types.Platforms {
+	seen := map[string]bool{}
+	for _, platform := range platforms {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if alias, ok := platformAliases[platform]; ok {
+			platform = alias
+		}
+		if slices.Contains(knownPlatforms, platform) {
+			seen[platform] = true
+		}
+	}
+
+	var normalized types.Platforms
+	for _, platform := range knownPlatforms {
+		if seen[platform] {
+			normalized = append(normalized, platform)
+		}
+	}
+	return normalized
+}
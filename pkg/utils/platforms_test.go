@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+func TestNormalizePlatforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		platforms []string
+		want      types.Platforms
+	}{
+		{name: "none", platforms: nil, want: nil},
+		{name: "known values", platforms: []string{"linux", "macos"}, want: types.Platforms{"macos", "linux"}},
+		{name: "case and whitespace", platforms: []string{" Linux ", "WINDOWS"}, want: types.Platforms{"linux", "windows"}},
+		{name: "macos aliases", platforms: []string{"Mac OS X", "darwin", "OSX"}, want: types.Platforms{"macos"}},
+		{name: "windows aliases", platforms: []string{"win32", "Win"}, want: types.Platforms{"windows"}},
+		{name: "windows through wsl", platforms: []string{"Windows (WSL)"}, want: types.Platforms{"wsl"}},
+		{name: "unknown values dropped", platforms: []string{"linux", "freebsd", "any", ""}, want: types.Platforms{"linux"}},
+		{name: "only unknown values", platforms: []string{"all platforms"}, want: nil},
+		{name: "duplicates collapsed", platforms: []string{"macos", "mac", "darwin", "linux", "Linux"}, want: types.Platforms{"macos", "linux"}},
+		{name: "display order", platforms: []string{"wsl", "windows", "linux", "macos"}, want: types.Platforms{"macos", "linux", "windows", "wsl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizePlatforms(tt.platforms)
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("NormalizePlatforms(%q) = %#v, want %#v", tt.platforms, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// RateLimiter is a token bucket shared by every goroutine that talks to the GitHub API.
+// When any caller hits a rate limit it can pause the bucket so all callers back off together.
+type RateLimiter struct {
+	mu          sync.Mutex
+	tokens      float64
+	burst       float64
+	interval    time.Duration
+	last        time.Time
+	pausedUntil time.Time
+
+	// slowInterval spreads the remaining quota reported by GitHub over the time left until slowUntil.
+	slowInterval time.Duration
+	slowUntil    time.Time
+}
+
+var (
+	// GitHubLimiter throttles GitHub core API calls (repositories, contents) made by the server and the scraper.
+	GitHubLimiter = NewRateLimiter(time.Second, 5)
+	// GitHubSearchLimiter throttles GitHub code search calls, which have a much smaller quota.
+	GitHubSearchLimiter = NewRateLimiter(6*time.Second, 1)
+)
+
+// NewRateLimiter returns a limiter that refills one token every interval, holding at most burst tokens.
+func NewRateLimiter(interval time.Duration, burst int) *RateLimiter {
+	return &RateLimiter{
+		tokens:   float64(burst),
+		burst:    float64(burst),
+		interval: interval,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if now.Before(l.pausedUntil) {
+			delay := l.pausedUntil.Sub(now)
+			l.mu.Unlock()
+			if err := SleepContext(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+
+		interval := l.interval
+		if now.Before(l.slowUntil) && l.slowInterval > interval {
+			interval = l.slowInterval
+		}
+
+		l.tokens += float64(now.Sub(l.last)) / float64(interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+
+		delay := time.Duration((1 - l.tokens) * float64(interval))
+		l.mu.Unlock()
+		if err := SleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Allow takes a token if one is available without waiting.
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return false
+	}
+
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// PauseUntil blocks all callers of Wait until t.
+func (l *RateLimiter) PauseUntil(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// Observe updates the limiter from the rate information GitHub returned with a response.
+// When the quota is nearly spent, calls are spread out over the time left until reset.
+func (l *RateLimiter) Observe(resp *github.Response) {
+	if resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+
+	reset := resp.Rate.Reset.Time
+	if resp.Rate.Remaining == 0 {
+		l.PauseUntil(reset)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.slowUntil = reset
+	l.slowInterval = time.Until(reset) / time.Duration(resp.Rate.Remaining)
+}
+
+// Do runs fn once the limiter allows it, transparently retrying when GitHub reports a primary
+// or secondary (abuse detection) rate limit.
+func (l *RateLimiter) Do(ctx context.Context, fn func() (*github.Response, error)) error {
+	for {
+		if err := l.Wait(ctx); err != nil {
+			return err
+		}
+
+		resp, err := fn()
+		l.Observe(resp)
+		githubRequests.WithLabelValues(githubStatus(resp, err)).Inc()
+		if rateErr, ok := err.(*github.RateLimitError); ok {
+			slog.Warn("Hit rate limit, waiting for reset", "wait", time.Until(rateErr.Rate.Reset.Time))
+			observeRateLimitWait("primary", time.Until(rateErr.Rate.Reset.Time))
+			l.PauseUntil(rateErr.Rate.Reset.Time)
+			continue
+		}
+		if abuseErr, ok := err.(*github.AbuseRateLimitError); ok {
+			// Secondary rate limits don't always include a Retry-After header
+			retryAfter := time.Minute
+			if abuseErr.RetryAfter != nil {
+				retryAfter = *abuseErr.RetryAfter
+			}
+			slog.Warn("Hit secondary rate limit, retrying", "wait", retryAfter)
+			observeRateLimitWait("secondary", retryAfter)
+			l.PauseUntil(time.Now().Add(retryAfter))
+			continue
+		}
+		return err
+	}
+}
+
+// githubStatus returns the HTTP status of a GitHub response for metrics, or "error" when the
+// request failed without one.
+func githubStatus(resp *github.Response, err error) string {
+	if resp != nil && resp.Response != nil {
+		return strconv.Itoa(resp.StatusCode)
+	}
+	if err != nil {
+		return "error"
+	}
+	return "unknown"
+}
+
+// SleepContext waits for d, returning the context error early if ctx is done first.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToolSearchPattern is one code search used to find tool definitions: files with the extension
+// that contain the marker.
+type ToolSearchPattern struct {
+	Extension string `json:"extension"`
+	Marker    string `json:"marker"`
+}
+
+// ToolSearchPatterns are searched in order by ScrapeToolDefinitions. They can be replaced at
+// startup with SetToolSearchPatterns.
+var ToolSearchPatterns = []ToolSearchPattern{
+	{Extension: "ts", Marker: "tool"},
+	{Extension: "py", Marker: "mcp.tool"},
+}
+
+var extensionPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// SetToolSearchPatterns validates and installs the tool search patterns. Duplicates are dropped.
+func SetToolSearchPatterns(patterns []ToolSearchPattern) error {
+	var result []ToolSearchPattern
+	seen := make(map[ToolSearchPattern]bool)
+	for i, pattern := range patterns {
+		pattern.Extension = strings.TrimPrefix(strings.TrimSpace(pattern.Extension), ".")
+		pattern.Marker = strings.TrimSpace(pattern.Marker)
+		if !extensionPattern.MatchString(pattern.Extension) {
+			return fmt.Errorf("pattern %d: invalid extension %q", i, pattern.Extension)
+		}
+		if pattern.Marker == "" || strings.ContainsAny(pattern.Marker, " \t\"") {
+			return fmt.Errorf("pattern %d: marker %q must be a single search term", i, pattern.Marker)
+		}
+		if !seen[pattern] {
+			seen[pattern] = true
+			result = append(result, pattern)
+		}
+	}
+	if len(result) == 0 {
+		return fmt.Errorf("the tool search pattern list is empty")
+	}
+
+	ToolSearchPatterns = result
+	return nil
+}
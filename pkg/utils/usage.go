@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// TokenCounter counts the OpenAI tokens, as reported in the usage field of each completion, spent
+// by the calls whose context carries it. A scrape attaches one to its context so that its budget
+// only counts its own analyses, not those of concurrent API requests.
+type TokenCounter struct {
+	tokens atomic.Int64
+}
+
+// Load returns the number of tokens counted so far.
+func (c *TokenCounter) Load() int64 {
+	return c.tokens.Load()
+}
+
+type tokenCounterKey struct{}
+
+// WithTokenCounter returns a copy of ctx whose OpenAI calls are counted by counter.
+func WithTokenCounter(ctx context.Context, counter *TokenCounter) context.Context {
+	return context.WithValue(ctx, tokenCounterKey{}, counter)
+}
+
+// addTokens adds tokens to the counter carried by ctx, if any.
+func addTokens(ctx context.Context, tokens int64) {
+	if counter, ok := ctx.Value(tokenCounterKey{}).(*TokenCounter); ok {
+		counter.tokens.Add(tokens)
+	}
+}
+
+func recordUsage(ctx context.Context, usage openai.Usage) {
+	addTokens(ctx, int64(usage.TotalTokens))
+}
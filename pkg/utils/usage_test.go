@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newUsageOpenAI returns a client whose chat completions answer content and report tokens used.
+func newUsageOpenAI(t *testing.T, content string, tokens int) *openai.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+			}},
+			Usage: openai.Usage{TotalTokens: tokens},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = srv.URL + "/v1"
+	return openai.NewClientWithConfig(config)
+}
+
+func TestTokenCounterCountsOnlyItsContext(t *testing.T) {
+	client := newUsageOpenAI(t, `{"configs":[]}`, 100)
+
+	var counter TokenCounter
+	if _, err := AnalyzeWithOpenAI(WithTokenCounter(context.Background(), &counter), client, "owner/a", "readme", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Calls without the counter, such as concurrent API requests, aren't charged to it
+	if _, err := AnalyzeWithOpenAI(context.Background(), client, "owner/b", "readme", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if counter.Load() != 100 {
+		t.Errorf("counted %d tokens, want 100", counter.Load())
+	}
+}
+
+func TestBatchChargesEveryCaller(t *testing.T) {
+	client := newUsageOpenAI(t, `{"results":[{"repository":"owner/a"},{"repository":"owner/b"},{"repository":"owner/c"}]}`, 10)
+
+	var scrape, request TokenCounter
+	scrapeCtx := WithTokenCounter(context.Background(), &scrape)
+	batch := []*batchRequest{
+		{ctx: scrapeCtx, repoName: "owner/a", result: make(chan *types.MCPServerManifest, 1)},
+		{ctx: scrapeCtx, repoName: "owner/b", result: make(chan *types.MCPServerManifest, 1)},
+		{ctx: WithTokenCounter(context.Background(), &request), repoName: "owner/c", result: make(chan *types.MCPServerManifest, 1)},
+	}
+	batcher.send(client, batch)
+
+	// 10 tokens over three repositories: 4, 3 and 3
+	if scrape.Load() != 7 || request.Load() != 3 {
+		t.Errorf("charged %d and %d tokens, want 7 and 3", scrape.Load(), request.Load())
+	}
+	for _, r := range batch {
+		if result := <-r.result; result == nil {
+			t.Errorf("no result for %s", r.repoName)
+		}
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"slices"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/google/go-github/v60/github"
 	"github.com/obot-platform/catalog-service/pkg/types"
@@ -26,109 +27,328 @@ func IsAuthorized(r *http.Request) bool {
 	return cookie.Value == expected
 }
 
+// NormalizePath trims surrounding slashes and collapses repeated slashes in a repository path.
+func NormalizePath(path string) string {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return strings.Join(result, "/")
+}
+
+// NormalizeFullName normalizes an owner/repo[/subpath] name. GitHub owner and repository
+// names are case-insensitive so they are lowercased; the subpath keeps its case.
+func NormalizeFullName(fullName string) string {
+	parts := strings.SplitN(NormalizePath(fullName), "/", 3)
+	for i := 0; i < len(parts) && i < 2; i++ {
+		parts[i] = strings.ToLower(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+// Subpath returns the directory of a server inside its repository, e.g. "src/github"
+// for "modelcontextprotocol/servers/src/github", or "" for a server at the repository root.
+func Subpath(fullName string) string {
+	parts := strings.SplitN(NormalizePath(fullName), "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// SetMonorepoFields marks servers that live in a subdirectory of a monorepo, whose GitHub
+// statistics describe the whole repository rather than the server itself.
+func SetMonorepoFields(repo *types.RepoInfo) {
+	repo.Subpath = Subpath(repo.FullName)
+	repo.RepoLevelStats = repo.Subpath != ""
+}
+
+// validJSON returns value if it is valid JSON and nil otherwise, logging when it had to drop a
+// non-empty value.
+func validJSON(fullName, column, value string) *string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if !json.Valid([]byte(value)) {
+		slog.Warn("Ignoring invalid JSON", "repo", fullName, "column", column)
+		return nil
+	}
+	return &value
+}
+
+// orEmpty returns *value, or an empty object for nil.
+func orEmpty(value *string) string {
+	if value == nil {
+		return "{}"
+	}
+	return *value
+}
+
 func SaveRepo(db *sql.DB, repo types.RepoInfo, proposed bool) (string, error) {
+	repo.FullName = NormalizeFullName(repo.FullName)
+	repo.Path = NormalizePath(repo.Path)
+
+	// Postgres rejects anything that is not JSON for the jsonb columns, which would lose the whole
+	// save. A new repository gets an empty object instead; an existing one keeps what is stored, so
+	// that a bad value can't wipe a curated manifest.
+	metadata := validJSON(repo.FullName, "metadata", repo.Metadata)
+	toolDefinitions := validJSON(repo.FullName, "tool_definitions", repo.ToolDefinitions)
+	manifest := validJSON(repo.FullName, "manifest", repo.Manifest)
+
+	// The manifest and its manifest_env rows are written together
+	tx, err := db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	// Check if repository already exists
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM repositories WHERE full_name = $1", repo.FullName).Scan(&count)
+	err = tx.QueryRow("SELECT COUNT(*) FROM repositories WHERE full_name = $1", repo.FullName).Scan(&count)
 	if err != nil {
 		return "", fmt.Errorf("error checking if repository exists: %v", err)
 	}
 
+	// repoID is only set when the live manifest may have been written
+	repoID := 0
+
 	if count > 0 {
 		// Update existing repository
 		if !proposed {
-			log.Printf("Updating repository %s without proposed manifest", repo.FullName)
-			_, err = db.Exec(`
+			slog.Info("Updating repository without proposed manifest", "repo", repo.FullName)
+			err = tx.QueryRow(`
 			UPDATE repositories 
 			SET url = $1, description = $2, display_name = $3, stars = $4, readme_content = $5, 
-				language = $6, path = $7, manifest = $8::jsonb, icon = $9, metadata = $10::jsonb, tool_definitions = $11::jsonb, proposed_manifest = $12::jsonb
-			WHERE full_name = $13
+				language = $6, path = $7, manifest = COALESCE($8::jsonb, manifest), icon = $9, metadata = COALESCE($10::jsonb, metadata),
+				tool_definitions = COALESCE($11::jsonb, tool_definitions), proposed_manifest = $12::jsonb, license = $13,
+				version = version + 1, analyzed_sha = COALESCE(NULLIF($15, ''), analyzed_sha), readme_sha = COALESCE(NULLIF($16, ''), readme_sha),
+				owner_icon = COALESCE(NULLIF($17, ''), owner_icon), last_scraped_at = CURRENT_TIMESTAMP
+			WHERE full_name = $14
+			RETURNING id
 		`, repo.URL, repo.Description, repo.DisplayName, repo.Stars, repo.ReadmeContent,
-				repo.Language, repo.Path, repo.Manifest, repo.Icon, repo.Metadata, repo.ToolDefinitions, "{}", repo.FullName)
+				repo.Language, repo.Path, manifest, repo.Icon, metadata, toolDefinitions, "{}", repo.License, repo.FullName, repo.AnalyzedSHA, repo.ReadmeSHA, repo.OwnerIcon).Scan(&repoID)
 		} else {
-			log.Printf("Updating repository %s with proposed manifest", repo.FullName)
-			_, err = db.Exec(`
+			slog.Info("Updating repository with proposed manifest", "repo", repo.FullName)
+			_, err = tx.Exec(`
 			UPDATE repositories 
 			SET url = $1, description = $2, display_name = $3, stars = $4, readme_content = $5, 
-				language = $6, path = $7, proposed_manifest = $8::jsonb, icon = $9, metadata = $10::jsonb, tool_definitions = $11::jsonb
-			WHERE full_name = $12
+				language = $6, path = $7, proposed_manifest = $8::jsonb, icon = $9, metadata = COALESCE($10::jsonb, metadata),
+				tool_definitions = COALESCE($11::jsonb, tool_definitions), license = $12,
+				analyzed_sha = COALESCE(NULLIF($14, ''), analyzed_sha), readme_sha = COALESCE(NULLIF($15, ''), readme_sha),
+				owner_icon = COALESCE(NULLIF($16, ''), owner_icon), last_scraped_at = CURRENT_TIMESTAMP
+			WHERE full_name = $13
 		`, repo.URL, repo.Description, repo.DisplayName, repo.Stars, repo.ReadmeContent,
-				repo.Language, repo.Path, repo.ProposedManifest, repo.Icon, repo.Metadata, repo.ToolDefinitions, repo.FullName)
+				repo.Language, repo.Path, repo.ProposedManifest, repo.Icon, metadata, toolDefinitions, repo.License, repo.FullName, repo.AnalyzedSHA, repo.ReadmeSHA, repo.OwnerIcon)
 		}
 		if err != nil {
 			return "", fmt.Errorf("error updating repository %s: %v", repo.FullName, err)
 		}
 	} else {
 		// Insert new repository
-		if repo.Metadata == "" {
-			repo.Metadata = "{}"
-		}
-		_, err = db.Exec(`
+		err = tx.QueryRow(`
 			INSERT INTO repositories 
-			(full_name, url, description, display_name, stars, readme_content, language, path, manifest, icon, metadata, tool_definitions) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			(full_name, url, description, display_name, stars, readme_content, language, path, manifest, icon, metadata, tool_definitions, license, analyzed_sha, readme_sha, owner_icon, last_scraped_at) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, CURRENT_TIMESTAMP)
+			RETURNING id
 		`, repo.FullName, repo.URL, repo.Description, repo.DisplayName, repo.Stars, repo.ReadmeContent,
-			repo.Language, repo.Path, []byte(repo.Manifest), repo.Icon, []byte(repo.Metadata), []byte(repo.ToolDefinitions))
+			repo.Language, repo.Path, []byte(orEmpty(manifest)), repo.Icon, []byte(orEmpty(metadata)), []byte(orEmpty(toolDefinitions)), repo.License, repo.AnalyzedSHA, repo.ReadmeSHA, repo.OwnerIcon).Scan(&repoID)
 		if err != nil {
 			return "", fmt.Errorf("error inserting repository %s: %v", repo.FullName, err)
 		}
 	}
+
+	// A manifest that was kept still has its manifest_env rows
+	if repoID != 0 && manifest != nil {
+		if err := SyncManifestEnv(tx, repoID, *manifest); err != nil {
+			return "", fmt.Errorf("error syncing env vars of repository %s: %v", repo.FullName, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error saving repository %s: %v", repo.FullName, err)
+	}
 	return repo.FullName, nil
 }
 
-func MarkPreferred(configs []types.MCPServerConfig) {
-	var preferredIndex = -1
+// NormalizeCategories trims and de-duplicates a comma separated category list returned by the
+// analyzer, dropping any category that isn't in types.Categories. This also drops categories
+// such as "Popular" that are computed server-side.
+func NormalizeCategories(categories string) string {
+	var result []string
+	for _, category := range strings.Split(categories, ",") {
+		category = strings.TrimSpace(category)
+		if !slices.Contains(types.Categories, category) || slices.Contains(result, category) {
+			continue
+		}
+		result = append(result, category)
+	}
+	return strings.Join(result, ",")
+}
 
-	// 1st priority: npx
-	for i, cfg := range configs {
-		if cfg.Command == "npx" {
-			preferredIndex = i
-			break
+// EditCategories adds and removes categories from a comma separated category list,
+// preserving the order of existing categories and de-duplicating the result.
+func EditCategories(categories string, add, remove []string) string {
+	var result []string
+	for _, category := range append(strings.Split(categories, ","), add...) {
+		category = strings.TrimSpace(category)
+		if category == "" || slices.Contains(remove, category) || slices.Contains(result, category) {
+			continue
 		}
+		result = append(result, category)
 	}
+	return strings.Join(result, ",")
+}
 
-	// 2nd priority: uv or uvx
-	if preferredIndex == -1 {
-		for i, cfg := range configs {
-			if cfg.Command == "uv" || cfg.Command == "uvx" {
-				preferredIndex = i
-				break
+// AICategoriesKey is the metadata key holding the categories assigned by the analyzer, as opposed
+// to the ones added by a human. Rows analyzed before it existed treat all their categories, except
+// Verified, as assigned by the analyzer.
+const AICategoriesKey = "aiCategories"
+
+// AnalyzerCategories returns the categories in metadata that were assigned by the analyzer.
+func AnalyzerCategories(metadata map[string]string) []string {
+	if categories, ok := metadata[AICategoriesKey]; ok {
+		return strings.Split(EditCategories(categories, nil, nil), ",")
+	}
+	return strings.Split(EditCategories(metadata["categories"], nil, []string{"Verified"}), ",")
+}
+
+// MergeAnalyzedCategories replaces the analyzer's previous categories in metadata with analyzed
+// while keeping the ones a human added. Human categories come first.
+func MergeAnalyzedCategories(metadata map[string]string, analyzed string) {
+	analyzed = NormalizeCategories(analyzed)
+	human := EditCategories(metadata["categories"], nil, AnalyzerCategories(metadata))
+	metadata["categories"] = EditCategories(human, strings.Split(analyzed, ","), nil)
+	metadata[AICategoriesKey] = analyzed
+}
+
+// MarkPreferred sets the Preferred flag on the config chosen by PreferredIndex.
+func MarkPreferred(configs []types.MCPServerConfig) {
+	if i := PreferredIndex(configs); i != -1 {
+		configs[i].Preferred = true
+	}
+}
+
+// ConfigKey identifies a config across analyses, which may reorder or re-describe configs: the
+// URL of a remote config, or the command and args of a command config.
+func ConfigKey(config types.MCPServerConfig) string {
+	if config.URL != "" {
+		return config.URL
+	}
+	return strings.Join(append([]string{config.Command}, config.Args...), " ")
+}
+
+// MarkChosenPreferred sets the Preferred flag on the config a curator chose, identified by its
+// ConfigKey, and clears it on all others. When chosenKey is empty or no config matches it any
+// longer, it falls back to MarkPreferred.
+func MarkChosenPreferred(configs []types.MCPServerConfig, chosenKey string) {
+	chosen := -1
+	if chosenKey != "" {
+		chosen = slices.IndexFunc(configs, func(config types.MCPServerConfig) bool {
+			return ConfigKey(config) == chosenKey
+		})
+	}
+	for i := range configs {
+		configs[i].Preferred = i == chosen
+	}
+	if chosen == -1 {
+		MarkPreferred(configs)
+	}
+}
+
+// chosenPreferredKey returns the ConfigKey of the config a curator chose as preferred for
+// fullName, or an empty string if the choice is left to MarkPreferred.
+func chosenPreferredKey(db *sql.DB, fullName string) string {
+	var key string
+	if err := db.QueryRow(`SELECT COALESCE(preferred_key, '') FROM repositories WHERE full_name = $1`, NormalizeFullName(fullName)).Scan(&key); err != nil && err != sql.ErrNoRows {
+		slog.Error("Error loading chosen preferred config", "repo", fullName, "error", err)
+	}
+	return key
+}
+
+// preferredCommandTiers lists the commands in order of preference, npx first, then uv or uvx,
+// then docker. PREFERRED_COMMAND_ORDER overrides it with a comma separated list of tiers in which
+// commands of equal priority are joined with "|", e.g. "npx,uv|uvx,docker".
+var preferredCommandTiers = sync.OnceValue(func() [][]string {
+	var tiers [][]string
+	for _, tier := range strings.Split(os.Getenv("PREFERRED_COMMAND_ORDER"), ",") {
+		var commands []string
+		for _, command := range strings.Split(tier, "|") {
+			if command = strings.TrimSpace(command); command != "" {
+				commands = append(commands, command)
 			}
 		}
+		if len(commands) > 0 {
+			tiers = append(tiers, commands)
+		}
 	}
-
-	// 3rd priority: docker
-	if preferredIndex == -1 {
+	if len(tiers) == 0 {
+		tiers = [][]string{{"npx"}, {"uv", "uvx"}, {"docker"}}
+	}
+	return tiers
+})
+
+// PreferredIndex returns the index of the config that should be preferred, or -1 if none
+// of them uses a supported command. It does not modify configs.
+func PreferredIndex(configs []types.MCPServerConfig) int {
+	for _, tier := range preferredCommandTiers() {
+		preferredIndex := -1
 		for i, cfg := range configs {
-			if cfg.Command == "docker" {
+			if !slices.Contains(tier, cfg.Command) {
+				continue
+			}
+			if preferredIndex == -1 || moreComplete(cfg, configs[preferredIndex]) {
 				preferredIndex = i
-				break
 			}
 		}
-	}
 
-	// Set the Prefer flag
-	if preferredIndex != -1 {
-		configs[preferredIndex].Preferred = true
+		if preferredIndex != -1 {
+			return preferredIndex
+		}
 	}
+	return -1
 }
 
-func AnalyzeWithOpenAI(openaiClient *openai.Client, repoName, readmeContent, existingConfig string) (types.MCPServerManifest, error) {
-	var result types.MCPServerManifest
-
-	// Create the prompt
-	prompt := fmt.Sprintf(`
-You are an expert in Model Context Protocol (MCP) servers. Analyze the following README from the repository %s:
+// moreComplete reports whether a documents its env vars better than b, preferring fewer args
+// when both are equally documented. Ties keep the earlier config.
+func moreComplete(a, b types.MCPServerConfig) bool {
+	if scoreA, scoreB := envMetadataScore(a), envMetadataScore(b); scoreA != scoreB {
+		return scoreA > scoreB
+	}
+	return len(a.Args) < len(b.Args)
+}
 
-%s
+// envMetadataScore counts the populated metadata fields of a config's env vars.
+func envMetadataScore(config types.MCPServerConfig) int {
+	score := 0
+	for _, env := range config.Env {
+		if env.Name != "" {
+			score++
+		}
+		if env.Description != "" {
+			score++
+		}
+		if env.Required {
+			score++
+		}
+	}
+	return score
+}
 
-Extract and provide the following data structure in JSON format:
+// analysisInstructions describes the manifest the analyzer extracts from a README. It is shared by
+// the single and the batched analysis prompts.
+func analysisInstructions() string {
+	return fmt.Sprintf(`Extract and provide the following data structure in JSON format:
 
 type OpenAIResponse struct {
 	Configs     []MCPServerConfig json:"configs"
 	Name        string            json:"name"
 	Description string            json:"description"
 	Category    string            json:"category"
+	Deprecated   bool   json:"deprecated"
+	SupersededBy string json:"supersededBy,omitempty"
+	Platforms    []string json:"platforms,omitempty"
 }
 
 type MCPServerConfig struct {
@@ -138,6 +358,7 @@ type MCPServerConfig struct {
 	HTTPHeaders []MCPPair json:"httpHeaders,omitempty"
 	URL         string    json:"url,omitempty"
 	URLDescription string    json:"urlDescription,omitempty"
+	SourceSnippet  string    json:"sourceSnippet,omitempty"
 }
 
 type MCPPair struct {
@@ -150,7 +371,7 @@ type MCPPair struct {
 	File        bool   json:"file,omitempty"
 }
 
-If the repository does not contain an MCP server, respond with an empty JSON object.
+If the repository does not contain an MCP server, respond with an empty configs list.
 
 For MCPServerConfig, you should look for a MCP server config in readme that looks like this:
 
@@ -160,27 +381,13 @@ For MCPServerConfig, you should look for a MCP server config in readme that look
 
 When generating category, pick from the following categories:
 
-Databases
-Data & Analytics
-File & Storage Systems
-Retrieval & Search
-SaaS & API Integrations
-Communication & Messaging
-Automation & Browsers
-Time & Scheduling
-Maps & Location
-Media & Design
-Memory & Reasoning
-Developer Tools
-Monitoring & Observability
-Infrastructure & DevOps
-Science & Research
-Finance & Commerce
+%s
 
 It can have multiple categories. connect them with comma.
 
 If config has url, it means it is SSE based MCP server. You should only populate url, urlDescription and headers. For url that has localhost, don't include it. You should only add header if there is a specific header option in the readme or config.
 If config has command, it means it is CLI based MCP server. You should only populate command, args and env.
+Only use the command that runs the MCP server. READMEs often show a separate installation step (for example "npm install -g foo", "pip install foo", "brew install foo" or "go install ..."). Never use an installation step as the command. If the README only shows an installation step followed by running the installed binary, use the binary as the command.
 
 When looking for Env in MCPServerConfig, The key of the environment variable and usually starts with UPPERCASE.
 The name of the environment variable is usually a friendly name representing the environment variable and it is usually starts with lowercase. File should be true if the value of the environment variable refers to a file path.
@@ -188,17 +395,39 @@ If you can't find any environment variables, you can return empty array for env.
 
 The description from OpenAIResponse should be concise and to the point on what this MCP server is for.
 
+Set deprecated to true only if the README explicitly says the server is deprecated, archived, no longer maintained or replaced by another project. If it names a replacement, set supersededBy to the URL of the replacement (use a GitHub or npm URL if only a name is given). Otherwise leave deprecated false and supersededBy empty.
+
+Set platforms only if the README states which operating systems the server runs on or which it doesn't support, for example "macOS only" or "requires WSL on Windows". Use only the values macos, linux, windows and wsl, where wsl means Windows through WSL only. List every supported platform, so a server that needs WSL on Windows but otherwise runs on macOS and Linux has ["macos", "linux", "wsl"]. If the README doesn't say, leave platforms empty; never guess from the language or the commands.
+
+Set sourceSnippet to the exact README excerpt the config was derived from, usually the fenced block containing the mcpServers config, copied verbatim. Keep it to the relevant block only.
+
 Make sure you can extract command, args and env from the mcp config example in the readme.
 It is usually wrapped into json block. For other MCPPair, you should look in the readme to find possible explaination.
 
 Return OpenAIResponse which contains a list of MCPServerManifest which supports docker, npx and uv and a category.
+`, strings.Join(types.Categories, "\n"))
+}
+
+func AnalyzeWithOpenAI(ctx context.Context, openaiClient *openai.Client, repoName, readmeContent, existingConfig string) (types.MCPServerManifest, error) {
+	var result types.MCPServerManifest
+
+	// Create the prompt
+	prompt := fmt.Sprintf(`
+You are an expert in Model Context Protocol (MCP) servers. Analyze the following README from the repository %s:
 
-`, repoName, readmeContent)
+%s
+
+%s
+`, repoName, readmeContent, analysisInstructions())
+
+	responseFormat, err := analysisResponseFormat()
+	if err != nil {
+		return result, fmt.Errorf("error building the analysis schema: %v", err)
+	}
 
 	// Call OpenAI API
-	resp, err := openaiClient.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
+	resp, err := withOpenAIRetry(ctx, "analyze", func() (openai.ChatCompletionResponse, error) {
+		return openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 			Model: openai.GPT4Dot1,
 			Messages: []openai.ChatCompletionMessage{
 				{
@@ -206,22 +435,20 @@ Return OpenAIResponse which contains a list of MCPServerManifest which supports
 					Content: prompt,
 				},
 			},
-			ResponseFormat: &openai.ChatCompletionResponseFormat{
-				Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-			},
-		},
-	)
-
+			ResponseFormat: responseFormat,
+		})
+	})
 	if err != nil {
 		return result, fmt.Errorf("OpenAI API error: %v", err)
 	}
+	recordUsage(ctx, resp.Usage)
 
 	if len(resp.Choices) == 0 {
 		return result, fmt.Errorf("no response from OpenAI")
 	}
 
 	// Parse the JSON response
-	err = json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result)
+	err = unmarshalOpenAIJSON("analyze", resp.Choices[0].Message.Content, &result)
 	if err != nil {
 		return result, fmt.Errorf("error parsing OpenAI response: %v", err)
 	}
@@ -229,6 +456,9 @@ Return OpenAIResponse which contains a list of MCPServerManifest which supports
 	return result, nil
 }
 
+// ErrNoMCPServer is returned by UpdateRepo when the analysis found no MCP server config.
+var ErrNoMCPServer = errors.New("no MCP server found")
+
 func UpdateRepo(ctx context.Context, repo types.RepoInfo, force bool, openaiClient *openai.Client, fullName, readmeContent string, db *sql.DB, githubClient *github.Client) (string, error) {
 	// if manifest exists and it is not forced, update proposed_manifest instead
 	proposed := true
@@ -236,53 +466,56 @@ func UpdateRepo(ctx context.Context, repo types.RepoInfo, force bool, openaiClie
 		proposed = false
 	}
 
-	// Analyze repository with OpenAI
-	analysis, err := AnalyzeWithOpenAI(openaiClient, fullName, readmeContent, repo.Manifest)
+	// Analyze repository with OpenAI. A failed analysis must not be saved: the stored manifest and
+	// tool definitions would be replaced with empty ones and the analyzed commit recorded, so the
+	// repository wouldn't be analyzed again until it changes.
+	analysis, err := analyzeReadme(ctx, openaiClient, fullName, readmeContent, repo.Manifest)
 	if err != nil {
-		log.Printf("Error analyzing repository %s: %v", fullName, err)
-	} else {
-		if len(analysis.Configs) == 0 {
-			return "", fmt.Errorf("no MCP server found in repository %s", fullName)
-		}
+		recordAnalysisFailure(db, fullName, repo.URL, err)
+		return "", fmt.Errorf("error analyzing repository %s: %w", fullName, err)
+	}
+	analysis.Configs = DedupeConfigs(DropInstallCommands(analysis.Configs))
+	if len(analysis.Configs) == 0 {
+		err := fmt.Errorf("%w in repository %s", ErrNoMCPServer, fullName)
+		recordAnalysisFailure(db, fullName, repo.URL, err)
+		return "", err
+	}
 
-		MarkPreferred(analysis.Configs)
+	// A config a curator chose stays preferred across analyses
+	MarkChosenPreferred(analysis.Configs, chosenPreferredKey(db, fullName))
 
-		manifestBytes, err := json.Marshal(analysis.Configs)
-		if err != nil {
-			return "", fmt.Errorf("error marshaling manifest for repository %s: %v", fullName, err)
+	manifestBytes, err := json.Marshal(analysis.Configs)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling manifest for repository %s: %v", fullName, err)
+	} else {
+		if proposed {
+			repo.ProposedManifest = string(manifestBytes)
 		} else {
-			if proposed {
-				repo.ProposedManifest = string(manifestBytes)
-			} else {
-				repo.Manifest = string(manifestBytes)
-			}
+			repo.Manifest = string(manifestBytes)
 		}
+	}
 
-		metadata := map[string]string{}
-		if repo.Metadata != "" {
-			err = json.Unmarshal([]byte(repo.Metadata), &metadata)
-			if err != nil {
-				return "", fmt.Errorf("error unmarshalling metadata for repository %s: %v", fullName, err)
-			}
-		}
-		verified := false
-		existingCategories := strings.Split(metadata["categories"], ",")
-		if slices.Contains(existingCategories, "Verified") {
-			verified = true
-		}
-		categories := analysis.Category
-		if verified {
-			categories = categories + ",Verified"
-		}
-		metadata["categories"] = categories
-		metadataBytes, err := json.Marshal(metadata)
+	metadata := map[string]string{}
+	if repo.Metadata != "" {
+		err = json.Unmarshal([]byte(repo.Metadata), &metadata)
 		if err != nil {
-			return "", fmt.Errorf("error marshaling metadata for repository %s: %v", fullName, err)
-		} else {
-			repo.Metadata = string(metadataBytes)
+			return "", fmt.Errorf("error unmarshalling metadata for repository %s: %v", fullName, err)
+		}
+	}
+	MergeAnalyzedCategories(metadata, analysis.Category)
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling metadata for repository %s: %v", fullName, err)
+	} else {
+		repo.Metadata = string(metadataBytes)
+	}
+	repo.Description = analysis.Description
+	repo.DisplayName = analysis.Name
+	if repo.DisplayName == "" {
+		// Name a monorepo server after its directory rather than after the whole repository
+		if subpath := Subpath(fullName); subpath != "" {
+			repo.DisplayName = subpath[strings.LastIndex(subpath, "/")+1:]
 		}
-		repo.Description = analysis.Description
-		repo.DisplayName = analysis.Name
 	}
 
 	foundPreferred := false
@@ -306,160 +539,214 @@ func UpdateRepo(ctx context.Context, repo types.RepoInfo, force bool, openaiClie
 		repo.ToolDefinitions = "{}"
 	}
 
-	return SaveRepo(db, repo, proposed)
+	savedName, err := SaveRepo(db, repo, proposed)
+	if err == nil {
+		clearAnalysisFailure(db, savedName)
+		if _, err := db.Exec(`UPDATE repositories SET deprecated = $1, superseded_by = NULLIF($2, ''), platforms = $3 WHERE full_name = $4`,
+			analysis.Deprecated, strings.TrimSpace(analysis.SupersededBy), NormalizePlatforms(analysis.Platforms), savedName); err != nil {
+			slog.Error("Error saving deprecation and platforms", "repo", savedName, "error", err)
+		}
+		if EmbeddingsEnabled {
+			if err := UpdateEmbedding(ctx, db, openaiClient, savedName, repo.DisplayName, repo.Description); err != nil {
+				slog.Error("Error updating embedding", "repo", savedName, "error", err)
+			}
+		}
+	}
+	return savedName, err
+}
+
+// recordAnalysisFailure counts an analysis of fullName that didn't produce a manifest, so repos
+// that keep wasting budget show up in GET /api/repos/failed-analysis.
+func recordAnalysisFailure(db *sql.DB, fullName, url string, analysisErr error) {
+	_, err := db.Exec(`
+		INSERT INTO analysis_failures (full_name, url, analysis_attempts, last_analysis_error, last_attempt_at)
+		VALUES ($1, $2, 1, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (full_name) DO UPDATE
+		SET url = EXCLUDED.url,
+			analysis_attempts = analysis_failures.analysis_attempts + 1,
+			last_analysis_error = EXCLUDED.last_analysis_error,
+			last_attempt_at = EXCLUDED.last_attempt_at
+	`, NormalizeFullName(fullName), url, analysisErr.Error())
+	if err != nil {
+		slog.Error("Error recording analysis failure", "repo", fullName, "error", err)
+	}
+}
 
+// clearAnalysisFailure forgets earlier failures once fullName has been analyzed successfully.
+func clearAnalysisFailure(db *sql.DB, fullName string) {
+	if _, err := db.Exec(`DELETE FROM analysis_failures WHERE full_name = $1`, NormalizeFullName(fullName)); err != nil {
+		slog.Error("Error clearing analysis failures", "repo", fullName, "error", err)
+	}
 }
 
-func ScrapeToolDefinitions(ctx context.Context, repo *types.RepoInfo, db *sql.DB, githubClient *github.Client, openaiClient *openai.Client) error {
-	for {
-		opts := &github.SearchOptions{
-			ListOptions: github.ListOptions{
-				PerPage: 1000,
-			},
-		}
-		parts := strings.Split(repo.FullName, "/")
+// GenerateStagingManifest analyzes the README and stores the result only in staging_manifest,
+// so prompt changes can be evaluated without touching the live or proposed manifests.
+func GenerateStagingManifest(ctx context.Context, repo types.RepoInfo, openaiClient *openai.Client, readmeContent string, db *sql.DB) error {
+	analysis, err := AnalyzeWithOpenAI(ctx, openaiClient, repo.FullName, readmeContent, repo.Manifest)
+	if err != nil {
+		return fmt.Errorf("error analyzing repository %s: %v", repo.FullName, err)
+	}
 
-		if len(parts) < 2 {
-			return fmt.Errorf("invalid repo name: %s", repo.FullName)
-		}
+	analysis.Configs = DedupeConfigs(DropInstallCommands(analysis.Configs))
+	if len(analysis.Configs) == 0 {
+		return fmt.Errorf("%w in repository %s", ErrNoMCPServer, repo.FullName)
+	}
+	MarkChosenPreferred(analysis.Configs, chosenPreferredKey(db, repo.FullName))
 
-		var allResults []*github.CodeResult
+	manifestBytes, err := json.Marshal(analysis.Configs)
+	if err != nil {
+		return fmt.Errorf("error marshaling staging manifest for repository %s: %v", repo.FullName, err)
+	}
 
-		query1 := fmt.Sprintf("tool extension:ts repo:%s/%s", parts[0], parts[1])
+	_, err = db.Exec(`UPDATE repositories SET staging_manifest = $1::jsonb WHERE full_name = $2`, manifestBytes, repo.FullName)
+	if err != nil {
+		return fmt.Errorf("error saving staging manifest for repository %s: %v", repo.FullName, err)
+	}
+	return nil
+}
 
-		result1, resp, err := githubClient.Search.Code(ctx, query1, opts)
-		if err != nil {
-			if _, ok := err.(*github.RateLimitError); ok {
-				log.Printf("Hit rate limit, waiting for reset after time %s...\n", time.Until(resp.Rate.Reset.Time))
-				time.Sleep(time.Until(resp.Rate.Reset.Time))
-				continue
-			}
-			return err
-		}
+func ScrapeToolDefinitions(ctx context.Context, repo *types.RepoInfo, db *sql.DB, githubClient *github.Client, openaiClient *openai.Client) error {
+	opts := &github.SearchOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 1000,
+		},
+	}
+	parts := strings.Split(repo.FullName, "/")
 
-		allResults = append(allResults, result1.CodeResults...)
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid repo name: %s", repo.FullName)
+	}
 
-		query2 := fmt.Sprintf("mcp.tool extension:py repo:%s/%s", parts[0], parts[1])
+	var allResults []*github.CodeResult
+	for _, pattern := range ToolSearchPatterns {
+		query := fmt.Sprintf("%s extension:%s repo:%s/%s", pattern.Marker, pattern.Extension, parts[0], parts[1])
 
-		result2, resp, err := githubClient.Search.Code(ctx, query2, opts)
+		var result *github.CodeSearchResult
+		err := GitHubSearchLimiter.Do(ctx, func() (resp *github.Response, err error) {
+			result, resp, err = githubClient.Search.Code(ctx, query, opts)
+			return resp, err
+		})
 		if err != nil {
-			if _, ok := err.(*github.RateLimitError); ok {
-				log.Printf("Hit rate limit, waiting for reset after time %s...\n", time.Until(resp.Rate.Reset.Time))
-				time.Sleep(time.Until(resp.Rate.Reset.Time))
-				continue
-			}
 			return err
 		}
 
-		allResults = append(allResults, result2.CodeResults...)
+		allResults = append(allResults, result.CodeResults...)
+	}
 
-		resultSet := make(map[string]*github.CodeResult)
-		for _, codeResult := range allResults {
-			resultSet[*codeResult.Repository.Owner.Login+"/"+*codeResult.Repository.Name+"/"+*codeResult.Path] = codeResult
-		}
+	resultSet := make(map[string]*github.CodeResult)
+	for _, codeResult := range allResults {
+		resultSet[*codeResult.Repository.Owner.Login+"/"+*codeResult.Repository.Name+"/"+*codeResult.Path] = codeResult
+	}
 
-		filteredResults := make([]*github.CodeResult, 0)
-		for _, codeResult := range resultSet {
-			filteredResults = append(filteredResults, codeResult)
-		}
+	filteredResults := make([]*github.CodeResult, 0)
+	for _, codeResult := range resultSet {
+		filteredResults = append(filteredResults, codeResult)
+	}
 
-		data := strings.Builder{}
+	data := strings.Builder{}
 
-		for _, codeResult := range filteredResults {
-			prefix := strings.TrimSuffix(repo.Path, "README.md")
-			if !strings.HasPrefix(*codeResult.Path, prefix) {
-				continue
-			}
+	for _, codeResult := range filteredResults {
+		prefix := strings.TrimSuffix(repo.Path, "README.md")
+		if !strings.HasPrefix(*codeResult.Path, prefix) {
+			continue
+		}
 
-			fileContent, _, _, err := githubClient.Repositories.GetContents(
+		var fileContent *github.RepositoryContent
+		err := GitHubLimiter.Do(ctx, func() (resp *github.Response, err error) {
+			fileContent, _, resp, err = githubClient.Repositories.GetContents(
 				ctx,
 				*codeResult.Repository.Owner.Login,
 				*codeResult.Repository.Name,
 				*codeResult.Path,
 				nil,
 			)
-			if err != nil {
-				return err
-			}
-
-			content, err := fileContent.GetContent()
-			if err != nil {
-				return err
-			}
+			return resp, err
+		})
+		if err != nil {
+			return err
+		}
 
-			data.WriteString(content)
+		content, err := fileContent.GetContent()
+		if err != nil {
+			return err
 		}
 
-		prompt := fmt.Sprintf(`
-		You are a helpful assistant that extracts tool definitions from a given code.
-		Here is the code:
-		%s
+		data.WriteString(content)
+	}
 
-		Tool data should be in json format. return ToolResponse.
+	prompt := fmt.Sprintf(`
+	You are a helpful assistant that extracts tool definitions from a given code.
+	Here is the code:
+	%s
 
-		type ToolResponse struct {
-			Tools []MCPTool json:"tools"
-		}
+	Tool data should be in json format. return ToolResponse.
 
-		type MCPTool struct {
-			Name        string      json:"name"
-			Description string      json:"description"
-			InputSchema InputSchema json:"inputSchema,omitempty"
-		}
+	type ToolResponse struct {
+		Tools []MCPTool json:"tools"
+	}
 
-		type InputSchema struct {
-			Properties map[string]Property json:"properties"
-		}
+	type MCPTool struct {
+		Name        string      json:"name"
+		Description string      json:"description"
+		InputSchema InputSchema json:"inputSchema,omitempty"
+	}
 
-		type Property struct {
-			Type        string json:"type"
-			Description string json:"description"
-			Required    bool   json:"required"
-		}
-		
-		The tool description should be concise and to the point on what this tool is for.
+	type InputSchema struct {
+		Properties map[string]Property json:"properties"
+	}
+
+	type Property struct {
+		Type        string json:"type"
+		Description string json:"description"
+		Required    bool   json:"required"
+	}
+	
+	The tool description should be concise and to the point on what this tool is for.
 
-		For typescript code, it can also be added through server.tool() method.
+	For typescript code, it can also be added through server.tool() method.
 
-		For python code, it is also added through @mcp.tool() decorator.
+	For python code, it is also added through @mcp.tool() decorator.
 
-		The properties description should be concise and to the point on what this tool parameter is for.
+	The properties description should be concise and to the point on what this tool parameter is for.
 
-		If you can't find any tool definitions, try to fetch tool from readme. return an empty ToolResponse. Don't hallucinate. You have readme as %s.
-		`, data.String(), repo.ReadmeContent)
+	If you can't find any tool definitions, try to fetch tool from readme. return an empty ToolResponse. Don't hallucinate. You have readme as %s.
+	`, data.String(), repo.ReadmeContent)
 
-		response, err := openaiClient.CreateChatCompletion(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model: openai.GPT4Dot1,
-				Messages: []openai.ChatCompletionMessage{
-					{
-						Role:    openai.ChatMessageRoleUser,
-						Content: prompt,
-					},
-				},
-				ResponseFormat: &openai.ChatCompletionResponseFormat{
-					Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	response, err := withOpenAIRetry(ctx, "tools", func() (openai.ChatCompletionResponse, error) {
+		return openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model: openai.GPT4Dot1,
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: prompt,
 				},
 			},
-		)
-		if err != nil {
-			return fmt.Errorf("error getting response from OpenAI: %v", err)
-		}
+			ResponseFormat: &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+			},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error getting response from OpenAI: %v", err)
+	}
+	recordUsage(ctx, response.Usage)
 
-		var tools types.ToolResponse
-		err = json.Unmarshal([]byte(response.Choices[0].Message.Content), &tools)
-		if err != nil {
-			return fmt.Errorf("error unmarshalling tools: %v", err)
-		}
+	if len(response.Choices) == 0 {
+		return fmt.Errorf("no response from OpenAI for the tool definitions of %s", repo.FullName)
+	}
 
-		toolRaw, err := json.Marshal(tools.Tools)
-		if err != nil {
-			return fmt.Errorf("error marshalling tools: %v", err)
-		}
+	var tools types.ToolResponse
+	err = unmarshalOpenAIJSON("tools", response.Choices[0].Message.Content, &tools)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling tools: %v", err)
+	}
 
-		log.Printf("Updating Tool definitions for %s", repo.FullName)
-		repo.ToolDefinitions = string(toolRaw)
-		return nil
+	toolRaw, err := json.Marshal(tools.Tools)
+	if err != nil {
+		return fmt.Errorf("error marshalling tools: %v", err)
 	}
+
+	slog.Info("Updating tool definitions", "repo", repo.FullName)
+	repo.ToolDefinitions = string(toolRaw)
+	return nil
 }
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/obot-platform/catalog-service/pkg/types"
+)
+
+func TestPreferredIndex(t *testing.T) {
+	var (
+		npx    = types.MCPServerConfig{Command: "npx", Args: []string{"-y", "server"}}
+		uv     = types.MCPServerConfig{Command: "uv", Args: []string{"run", "server"}}
+		uvx    = types.MCPServerConfig{Command: "uvx", Args: []string{"server"}}
+		docker = types.MCPServerConfig{Command: "docker", Args: []string{"run", "-i", "image"}}
+		node   = types.MCPServerConfig{Command: "node", Args: []string{"dist/index.js"}}
+		remote = types.MCPServerConfig{URL: "https://example.com/mcp"}
+	)
+	tests := []struct {
+		name    string
+		configs []types.MCPServerConfig
+		want    int
+	}{
+		{name: "empty", configs: nil, want: -1},
+		{name: "npx only", configs: []types.MCPServerConfig{npx}, want: 0},
+		{name: "uv only", configs: []types.MCPServerConfig{node, uv}, want: 1},
+		{name: "uvx only", configs: []types.MCPServerConfig{uvx}, want: 0},
+		{name: "docker only", configs: []types.MCPServerConfig{remote, docker}, want: 1},
+		{name: "npx before uv and docker", configs: []types.MCPServerConfig{docker, uvx, npx}, want: 2},
+		{name: "uv before docker", configs: []types.MCPServerConfig{docker, node, uv}, want: 2},
+		{name: "unsupported commands", configs: []types.MCPServerConfig{node, remote}, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreferredIndex(tt.configs); got != tt.want {
+				t.Errorf("PreferredIndex() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferredIndexDoesNotModify(t *testing.T) {
+	configs := []types.MCPServerConfig{{Command: "docker"}, {Command: "npx"}}
+	PreferredIndex(configs)
+	for i, config := range configs {
+		if config.Preferred {
+			t.Errorf("config %d was marked preferred", i)
+		}
+	}
+}
+
+func TestMarkPreferred(t *testing.T) {
+	configs := []types.MCPServerConfig{{Command: "docker"}, {Command: "npx"}}
+	MarkPreferred(configs)
+	if configs[0].Preferred || !configs[1].Preferred {
+		t.Errorf("preferred = %v, %v, want only the npx config", configs[0].Preferred, configs[1].Preferred)
+	}
+
+	// No supported command leaves all configs unmarked
+	configs = []types.MCPServerConfig{{Command: "node"}}
+	MarkPreferred(configs)
+	if configs[0].Preferred {
+		t.Error("node config was marked preferred")
+	}
+}
+
+func TestPreferredIndexTies(t *testing.T) {
+	documented := []types.MCPPair{{Key: "API_KEY", Name: "api key", Description: "Key for the API", Required: true}}
+	undocumented := []types.MCPPair{{Key: "API_KEY"}}
+
+	tests := []struct {
+		name    string
+		configs []types.MCPServerConfig
+		want    int
+	}{
+		{
+			name: "better documented env wins",
+			configs: []types.MCPServerConfig{
+				{Command: "npx", Args: []string{"-y", "server"}, Env: undocumented},
+				{Command: "npx", Args: []string{"-y", "@scope/server"}, Env: documented},
+			},
+			want: 1,
+		},
+		{
+			name: "fewer args break an env tie",
+			configs: []types.MCPServerConfig{
+				{Command: "npx", Args: []string{"-y", "server", "--stdio"}, Env: documented},
+				{Command: "npx", Args: []string{"-y", "server"}, Env: documented},
+			},
+			want: 1,
+		},
+		{
+			name: "full tie keeps the first",
+			configs: []types.MCPServerConfig{
+				{Command: "uvx", Args: []string{"server-a"}},
+				{Command: "uv", Args: []string{"server-b"}},
+			},
+			want: 0,
+		},
+		{
+			name: "documentation doesn't beat a better command",
+			configs: []types.MCPServerConfig{
+				{Command: "docker", Args: []string{"run", "image"}, Env: documented},
+				{Command: "npx", Args: []string{"-y", "server"}},
+			},
+			want: 1,
+		},
+		{
+			name: "same result in either order",
+			configs: []types.MCPServerConfig{
+				{Command: "npx", Args: []string{"-y", "@scope/server"}, Env: documented},
+				{Command: "npx", Args: []string{"-y", "server"}, Env: undocumented},
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreferredIndex(tt.configs); got != tt.want {
+				t.Errorf("PreferredIndex() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"/":                       "",
+		"README.md":               "README.md",
+		"/src//github/README.md/": "src/github/README.md",
+		"  src/github  ":          "src/github",
+		"Src/GitHub":              "Src/GitHub",
+	}
+	for path, want := range tests {
+		if got := NormalizePath(path); got != want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestNormalizeFullName(t *testing.T) {
+	tests := map[string]string{
+		"Owner/Repo":   "owner/repo",
+		"owner/repo/":  "owner/repo",
+		"/Owner//Repo": "owner/repo",
+		"ModelContextProtocol/Servers/Src/GitHub": "modelcontextprotocol/servers/Src/GitHub",
+		"owner/repo//src///github/":               "owner/repo/src/github",
+		"Owner":                                   "owner",
+	}
+	for fullName, want := range tests {
+		if got := NormalizeFullName(fullName); got != want {
+			t.Errorf("NormalizeFullName(%q) = %q, want %q", fullName, got, want)
+		}
+	}
+}
+
+func TestSubpath(t *testing.T) {
+	tests := map[string]string{
+		"owner/repo":  "",
+		"owner/repo/": "",
+		"modelcontextprotocol/servers/src/github": "src/github",
+		"owner/repo//Src//GitHub/":                "Src/GitHub",
+		"owner":                                   "",
+	}
+	for fullName, want := range tests {
+		if got := Subpath(fullName); got != want {
+			t.Errorf("Subpath(%q) = %q, want %q", fullName, got, want)
+		}
+	}
+}
+
+func TestMergeAnalyzedCategories(t *testing.T) {
+	tests := []struct {
+		name           string
+		metadata       map[string]string
+		analyzed       string
+		wantCategories string
+		wantAI         string
+	}{
+		{
+			name:           "analyzer categories replaced, human ones kept first",
+			metadata:       map[string]string{"categories": "Databases,Verified,Media & Design", AICategoriesKey: "Databases"},
+			analyzed:       "Developer Tools,Databases",
+			wantCategories: "Verified,Media & Design,Developer Tools,Databases",
+			wantAI:         "Developer Tools,Databases",
+		},
+		{
+			name:           "row from before aiCategories keeps only Verified",
+			metadata:       map[string]string{"categories": "Databases,Verified"},
+			analyzed:       "Developer Tools",
+			wantCategories: "Verified,Developer Tools",
+			wantAI:         "Developer Tools",
+		},
+		{
+			name:           "empty analysis drops the analyzer's categories",
+			metadata:       map[string]string{"categories": "Databases,Verified", AICategoriesKey: "Databases"},
+			analyzed:       "",
+			wantCategories: "Verified",
+			wantAI:         "",
+		},
+		{
+			name:           "human category the analyzer also picks isn't duplicated",
+			metadata:       map[string]string{"categories": "Verified,Databases", AICategoriesKey: ""},
+			analyzed:       "Databases, Developer Tools",
+			wantCategories: "Verified,Databases,Developer Tools",
+			wantAI:         "Databases,Developer Tools",
+		},
+		{
+			name:           "unknown analyzed categories are dropped",
+			metadata:       map[string]string{},
+			analyzed:       "Databases,Made Up",
+			wantCategories: "Databases",
+			wantAI:         "Databases",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MergeAnalyzedCategories(tt.metadata, tt.analyzed)
+			if got := tt.metadata["categories"]; got != tt.wantCategories {
+				t.Errorf("categories = %q, want %q", got, tt.wantCategories)
+			}
+			if got := tt.metadata[AICategoriesKey]; got != tt.wantAI {
+				t.Errorf("%s = %q, want %q", AICategoriesKey, got, tt.wantAI)
+			}
+		})
+	}
+}
+
+func TestMergeAnalyzedCategoriesRepeated(t *testing.T) {
+	metadata := map[string]string{"categories": "Verified,Media & Design", AICategoriesKey: ""}
+	MergeAnalyzedCategories(metadata, "Databases")
+	MergeAnalyzedCategories(metadata, "Developer Tools")
+	MergeAnalyzedCategories(metadata, "Developer Tools")
+
+	if want := "Verified,Media & Design,Developer Tools"; metadata["categories"] != want {
+		t.Errorf("categories = %q, want %q", metadata["categories"], want)
+	}
+}